@@ -0,0 +1,100 @@
+package arg
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	scalar "github.com/alexflint/go-scalar"
+)
+
+// Enumerated is the interface that a type can implement to restrict options of
+// that type to a fixed set of values.
+type Enumerated interface {
+	// Choices returns the values that are accepted on the command line, in the
+	// order in which they will be listed in the help message.
+	Choices() []string
+}
+
+// choicesOf gets the choices listed by a type that implements Enumerated, or by
+// the element type of a slice or array, or nil if there are none
+func choicesOf(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if e, ok := reflect.New(t).Interface().(Enumerated); ok {
+		return e.Choices()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if e, ok := reflect.New(t).Interface().(Enumerated); ok {
+			return e.Choices()
+		}
+	}
+	return nil
+}
+
+// checkChoices checks that each of the choices for an option can be parsed into
+// the type of its field, or into the element type for a slice or array
+func checkChoices(spec *spec) error {
+	t := spec.field.Type
+	switch {
+	case spec.cardinality == zero:
+		return fmt.Errorf("choices are not supported for boolean flags")
+	case spec.cardinality == multiple:
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Map {
+			return fmt.Errorf("choices are not supported for map fields")
+		}
+		t = t.Elem()
+	}
+
+	for _, choice := range spec.choices {
+		if err := scalar.ParseValue(reflect.New(t).Elem(), choice); err != nil {
+			return fmt.Errorf("error processing choice %q: %v", choice, err)
+		}
+	}
+	return nil
+}
+
+// checkChoice returns an error if an option is restricted to a set of choices
+// and the given value is not one of them
+func checkChoice(spec *spec, value string) error {
+	if spec.choices == nil || contains(spec.choices, value) {
+		return nil
+	}
+	return fmt.Errorf("invalid choice %q (choose from %s)", value, strings.Join(spec.choices, ", "))
+}
+
+// setScalar checks a value against the choices for an option and then parses it
+// into the field for the option
+func (p *Parser) setScalar(spec *spec, value string) error {
+	if err := checkChoice(spec, value); err != nil {
+		return err
+	}
+	return scalar.ParseValue(p.val(spec.dest), value)
+}
+
+// setMultiple checks each value against the choices for an option and then
+// parses them into the slice, array, or map for the option
+func (p *Parser) setMultiple(spec *spec, values []string, clear bool) error {
+	for _, value := range values {
+		if err := checkChoice(spec, value); err != nil {
+			return err
+		}
+	}
+	return setSliceOrMap(p.val(spec.dest), values, clear)
+}
+
+// withChoices describes the choices for an option for the help text
+func withChoices(choices []string) string {
+	if choices == nil {
+		return ""
+	}
+	return "choices: " + strings.Join(choices, ", ")
+}
@@ -0,0 +1,139 @@
+package arg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logLevel int
+
+func (l logLevel) Choices() []string {
+	return []string{"debug", "info"}
+}
+
+func (l *logLevel) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "debug":
+		*l = 0
+	case "info", "information":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level %s", b)
+	}
+	return nil
+}
+
+func TestChoices(t *testing.T) {
+	var args struct {
+		Level string `choices:"debug|info|warn|error"`
+	}
+	err := parse("--level warn", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "warn", args.Level)
+}
+
+func TestChoicesInvalid(t *testing.T) {
+	var args struct {
+		Level string `choices:"debug|info|warn|error"`
+	}
+	err := parse("--level trace", &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "trace", e.Value)
+	assert.EqualError(t, err, `error processing --level: invalid choice "trace" (choose from debug, info, warn, error)`)
+}
+
+func TestChoicesFromEnv(t *testing.T) {
+	var args struct {
+		Level string `arg:"env:CHOICES_LEVEL" choices:"debug|info"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"CHOICES_LEVEL=info"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "info", args.Level)
+
+	_, err = parseWithEnv(Config{}, "", []string{"CHOICES_LEVEL=trace"}, &args)
+	assert.EqualError(t, err, `error processing environment variable CHOICES_LEVEL: invalid choice "trace" (choose from debug, info)`)
+}
+
+func TestChoicesSliceAndPositional(t *testing.T) {
+	var args struct {
+		Formats []string `choices:"json|yaml"`
+		Mode    string   `arg:"positional" choices:"fast|slow"`
+	}
+	err := parse("fast --formats json yaml", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"json", "yaml"}, args.Formats)
+	assert.Equal(t, "fast", args.Mode)
+
+	err = parse("fast --formats json xml", &args)
+	assert.EqualError(t, err, `error processing --formats: invalid choice "xml" (choose from json, yaml)`)
+
+	err = parse("medium", &args)
+	assert.EqualError(t, err, `error processing MODE: invalid choice "medium" (choose from fast, slow)`)
+}
+
+func TestChoicesIntegers(t *testing.T) {
+	var args struct {
+		Level int `choices:"1|2|3"`
+	}
+	err := parse("--level 2", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 2, args.Level)
+
+	err = parse("--level 4", &args)
+	assert.Error(t, err)
+}
+
+func TestEnumerated(t *testing.T) {
+	var args struct {
+		Level  logLevel
+		Levels []logLevel
+	}
+	err := parse("--level info --levels debug info", &args)
+	require.NoError(t, err)
+	assert.Equal(t, logLevel(1), args.Level)
+	assert.Equal(t, []logLevel{0, 1}, args.Levels)
+
+	// values accepted by UnmarshalText are still restricted to the choices
+	err = parse("--level information", &args)
+	assert.EqualError(t, err, `error processing --level: invalid choice "information" (choose from debug, info)`)
+}
+
+func TestChoicesTagOverridesEnumerated(t *testing.T) {
+	var args struct {
+		Level logLevel `choices:"info"`
+	}
+	err := parse("--level debug", &args)
+	assert.EqualError(t, err, `error processing --level: invalid choice "debug" (choose from info)`)
+}
+
+func TestChoicesTagErrors(t *testing.T) {
+	var boolean struct {
+		Verbose bool `choices:"true"`
+	}
+	err := parse("", &boolean)
+	assert.EqualError(t, err, ".Verbose: choices are not supported for boolean flags")
+
+	var wrongType struct {
+		Level int `choices:"1|two"`
+	}
+	err = parse("", &wrongType)
+	assert.EqualError(t, err, `.Level: error processing choice "two": strconv.ParseInt: parsing "two": invalid syntax`)
+
+	var badDefault struct {
+		Level string `choices:"debug|info" default:"warn"`
+	}
+	err = parse("", &badDefault)
+	assert.EqualError(t, err, `.Level: default value "warn" is not one of the choices`)
+
+	var mapField struct {
+		Labels map[string]string `choices:"a|b"`
+	}
+	err = parse("", &mapField)
+	assert.EqualError(t, err, ".Labels: choices are not supported for map fields")
+}
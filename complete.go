@@ -0,0 +1,292 @@
+package arg
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Completer is the interface that the type of a field can implement to supply the
+// candidates for its value during completion. For slices, the element type is
+// checked instead. As an alternative, the struct that contains a field can have a
+// method named Complete followed by the name of the field, such as CompleteCluster
+// for a field named Cluster, with the same signature as Complete.
+type Completer interface {
+	// Complete returns the candidates for a value that starts with the given
+	// prefix. Candidates that do not start with the prefix are ignored.
+	Complete(prefix string) []string
+}
+
+// CompletionDirective tells the shell what to offer in addition to the candidates
+type CompletionDirective int
+
+const (
+	CompleteFiles   CompletionDirective = iota // also offer file names
+	CompleteDirs                               // also offer directory names
+	CompleteNoFiles                            // offer only the candidates
+)
+
+func (d CompletionDirective) String() string {
+	switch d {
+	case CompleteFiles:
+		return "files"
+	case CompleteDirs:
+		return "dirs"
+	case CompleteNoFiles:
+		return "nofiles"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(d))
+	}
+}
+
+// Complete gets the candidates for the last of the given words, which is the word
+// under the cursor and may be empty, given the words before it. The earlier words
+// are parsed into the destination structs, ignoring any errors, so that completers
+// can depend on the values of options that were given before the cursor. Values
+// are still filled in from the environment, config files, and defaults, but
+// required options, groups, dependencies, and validate tags are not checked, and
+// no Validate methods are called.
+//
+// When Config.CompletionFlag is set, Parse treats "__complete" as the first
+// argument as a request for completion and returns ErrCompletion, and MustParse
+// then prints each candidate on its own line followed by a line containing a colon
+// and the directive, such as ":files". The scripts from WriteCompletion use this
+// to complete the values of fields that have completers.
+func (p *Parser) Complete(words []string) ([]string, CompletionDirective) {
+	var cur string
+	if len(words) > 0 {
+		cur = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	// fill in the values given so far so that completers can use them
+	p.completing = true
+	p.process(words)
+	p.completing = false
+
+	// follow the words before the cursor in the same way as process
+	cmd := p.cmd
+	specs := append([]*spec{}, cmd.specs...)
+	var positionals int
+	var allpositional bool
+	var pending *spec // the option that takes the next word as its value
+	var remaining int // the number of values that pending can still take, or -1 for any number
+	for _, word := range words {
+		// as in process, "--" ends the values of an option
+		if pending != nil && word != "--" && isValue(word, pending.field.Type, specs) {
+			if remaining > 0 {
+				remaining--
+			}
+			if remaining == 0 {
+				pending = nil
+			}
+			continue
+		}
+		pending = nil
+
+		if word == "--" && !allpositional {
+			allpositional = true
+			continue
+		}
+
+		if !isFlag(word) || allpositional {
+			if len(cmd.subcommands) == 0 {
+				positionals++
+				continue
+			}
+			subcmd := findSubcommand(cmd.subcommands, word)
+			if subcmd == nil && p.config.AllowSubcommandAbbreviations {
+				subcmd, _ = findAbbreviatedSubcommand(cmd.subcommands, word)
+			}
+			if subcmd == nil {
+				continue
+			}
+			if p.config.StrictSubcommands {
+				specs = append([]*spec{}, subcmd.specs...)
+			} else {
+				specs = append(specs, subcmd.specs...)
+			}
+			cmd = subcmd
+			continue
+		}
+
+		spec, _, attached := p.findCompletionOption(specs, word)
+		if spec == nil || attached || !takesValue(spec) {
+			continue
+		}
+		pending, remaining = spec, 1
+		if spec.cardinality == multiple && !spec.separate {
+			remaining = -1
+			if spec.maxValues > 0 {
+				remaining = spec.maxValues
+			}
+		}
+	}
+
+	// complete the word under the cursor
+	switch {
+	case pending != nil && !isFlag(cur) && cur != "--":
+		return p.completeValue(pending, cur, "")
+	case strings.HasPrefix(cur, "-") && !allpositional:
+		if spec, value, attached := p.findCompletionOption(specs, cur); attached {
+			if spec == nil || spec.cardinality == zero {
+				return nil, CompleteNoFiles
+			}
+			return p.completeValue(spec, value, cur[:len(cur)-len(value)])
+		}
+		var candidates []string
+		for _, spec := range p.completionOptions(cmd) {
+			candidates = append(candidates, optionWords(spec)...)
+		}
+		return filterCandidates(candidates, cur, ""), CompleteNoFiles
+	case len(cmd.subcommands) > 0:
+		var names []string
+		for _, subcmd := range cmd.subcommands {
+			if !subcmd.hidden {
+				names = append(names, subcmd.name)
+			}
+		}
+		return filterCandidates(names, cur, ""), CompleteNoFiles
+	}
+
+	for _, spec := range cmd.specs {
+		if !spec.positional {
+			continue
+		}
+		if positionals == 0 || spec.cardinality == multiple {
+			return p.completeValue(spec, cur, "")
+		}
+		positionals--
+	}
+	return nil, CompleteNoFiles
+}
+
+// findCompletionOption finds the spec for a word such as "--name", "--name=x",
+// "-vn", or "-vnx" in the same way as process, expanding abbreviations if they are
+// allowed. For a cluster of short options it finds the last one. It also returns
+// the value attached to the word and whether there is one, which is true for
+// "--name=" even though the value is empty.
+func (p *Parser) findCompletionOption(specs []*spec, word string) (*spec, string, bool) {
+	if p.config.AllowAbbreviations && strings.HasPrefix(word, "--") {
+		if expanded, err := expandAbbreviation(specs, nil, word); err == nil {
+			word = expanded
+		}
+	}
+	opt := strings.TrimLeft(word, "-")
+	var value string
+	var attached bool
+	if pos := strings.Index(opt, "="); pos != -1 {
+		opt, value, attached = opt[:pos], opt[pos+1:], true
+	}
+	if opt == "" {
+		return nil, value, attached
+	}
+	if spec := findOption(specs, opt); spec != nil {
+		return spec, value, attached
+	}
+
+	if !strings.HasPrefix(word, "--") {
+		if cluster, value := findShortCluster(specs, word[1:]); cluster != nil {
+			var n int
+			for _, spec := range cluster {
+				n += len(spec.short)
+			}
+			return cluster[len(cluster)-1], value, len(word) > 1+n
+		}
+	}
+
+	// the negated form of an option never takes a value
+	return findNegatedOption(specs, opt), value, attached
+}
+
+// completeValue gets the candidates for the value of an option or positional
+// argument, each of which is prefixed with lead
+func (p *Parser) completeValue(spec *spec, prefix, lead string) ([]string, CompletionDirective) {
+	if complete := p.completerFor(spec); complete != nil {
+		return filterCandidates(complete(prefix), prefix, lead), CompleteNoFiles
+	}
+	if len(spec.choices) > 0 {
+		return filterCandidates(spec.choices, prefix, lead), CompleteNoFiles
+	}
+
+	for _, rule := range spec.validation {
+		if rule.name == "dir" {
+			return nil, CompleteDirs
+		}
+	}
+	t := elementType(spec.field.Type)
+	if t.Kind() == reflect.Bool || isNumeric(t) {
+		return nil, CompleteNoFiles
+	}
+	return nil, CompleteFiles
+}
+
+// completerFor gets the function that completes the value of an option, which
+// is either a method on the struct containing the field or the Complete method
+// of the type of the field, or nil if there is none
+func (p *Parser) completerFor(spec *spec) func(string) []string {
+	// the builtin options have no field
+	if len(spec.dest.fields) == 0 {
+		return nil
+	}
+
+	owner := p.val(path{root: spec.dest.root, fields: spec.dest.fields[:len(spec.dest.fields)-1]})
+	if owner.IsValid() {
+		if owner.Kind() == reflect.Ptr && owner.IsNil() {
+			owner = reflect.New(owner.Type().Elem())
+		}
+		if m := owner.MethodByName("Complete" + spec.field.Name); m.IsValid() {
+			if complete, ok := m.Interface().(func(string) []string); ok {
+				return complete
+			}
+		}
+	}
+
+	t := elementType(spec.field.Type)
+	v := reflect.New(t)
+	if field := p.val(spec.dest); field.IsValid() && field.Type() == t && field.CanAddr() && field.CanInterface() {
+		v = field.Addr()
+	}
+	if c, ok := v.Interface().(Completer); ok {
+		return c.Complete
+	}
+	return nil
+}
+
+// elementType gets the type of a single value of a field, which is the element
+// type for slices and arrays, without any pointers
+func elementType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// filterCandidates keeps the candidates that start with prefix and adds lead to
+// the start of each one
+func filterCandidates(candidates []string, prefix, lead string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, lead+c)
+		}
+	}
+	return out
+}
+
+// writeCandidates writes the candidates for the last of the given words in the
+// form that the completion scripts expect
+func (p *Parser) writeCandidates(w io.Writer, words []string) {
+	candidates, directive := p.Complete(words)
+	for _, c := range candidates {
+		fmt.Fprintln(w, c)
+	}
+	fmt.Fprintf(w, ":%s\n", directive)
+}
@@ -0,0 +1,246 @@
+package arg
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type clusterName string
+
+func (clusterName) Complete(prefix string) []string {
+	return []string{"prod-east", "prod-west", "staging"}
+}
+
+type completeDeploy struct {
+	Cluster   clusterName `arg:"-c"`
+	Namespace string
+	Services  []string `arg:"positional"`
+}
+
+// CompleteNamespace depends on the cluster given before it
+func (d *completeDeploy) CompleteNamespace(prefix string) []string {
+	if d.Cluster == "staging" {
+		return []string{"scratch"}
+	}
+	return []string{"default", "kube-system"}
+}
+
+func (d *completeDeploy) CompleteServices(prefix string) []string {
+	return []string{"api", "web", "worker"}
+}
+
+type completeArgs struct {
+	Verbose bool   `arg:"-v"`
+	Level   string `choices:"debug|info"`
+	Workdir string `validate:"dir"`
+	Count   int
+	Deploy  *completeDeploy `arg:"subcommand:deploy"`
+	Logs    *struct {
+		Files []string `arg:"positional"`
+	} `arg:"subcommand:logs"`
+	Admin *struct{} `arg:"subcommand:admin,hidden"`
+}
+
+func complete(t *testing.T, cmdline ...string) ([]string, CompletionDirective) {
+	var args completeArgs
+	p, err := NewParser(Config{}, &args)
+	require.NoError(t, err)
+	return p.Complete(cmdline)
+}
+
+func TestCompleteSubcommands(t *testing.T) {
+	candidates, directive := complete(t, "")
+	assert.Equal(t, []string{"deploy", "logs"}, candidates)
+	assert.Equal(t, CompleteNoFiles, directive)
+
+	candidates, _ = complete(t, "--verbose", "d")
+	assert.Equal(t, []string{"deploy"}, candidates)
+}
+
+func TestCompleteOptions(t *testing.T) {
+	candidates, directive := complete(t, "--")
+	assert.Equal(t, []string{"--verbose", "--level", "--workdir", "--count", "--help"}, candidates)
+	assert.Equal(t, CompleteNoFiles, directive)
+
+	candidates, _ = complete(t, "deploy", "--n")
+	assert.Equal(t, []string{"--namespace"}, candidates)
+}
+
+func TestCompleteChoices(t *testing.T) {
+	candidates, directive := complete(t, "--level", "")
+	assert.Equal(t, []string{"debug", "info"}, candidates)
+	assert.Equal(t, CompleteNoFiles, directive)
+
+	candidates, _ = complete(t, "--level=d")
+	assert.Equal(t, []string{"--level=debug"}, candidates)
+}
+
+func TestCompleteShortOptions(t *testing.T) {
+	var args struct {
+		Verbose bool   `arg:"-v"`
+		Level   string `arg:"-l" choices:"debug|info"`
+		User    string `arg:"positional" choices:"alice|bob"`
+	}
+	p, err := NewParser(Config{}, &args)
+	require.NoError(t, err)
+
+	candidates, _ := p.Complete([]string{"-vl", ""})
+	assert.Equal(t, []string{"debug", "info"}, candidates)
+
+	candidates, _ = p.Complete([]string{"-l="})
+	assert.Equal(t, []string{"-l=debug", "-l=info"}, candidates)
+
+	candidates, _ = p.Complete([]string{"-vl=d"})
+	assert.Equal(t, []string{"-vl=debug"}, candidates)
+
+	candidates, _ = p.Complete([]string{"-vli"})
+	assert.Equal(t, []string{"-vlinfo"}, candidates)
+
+	// a value attached to the cluster is not followed by another
+	candidates, _ = p.Complete([]string{"-vldebug", ""})
+	assert.Equal(t, []string{"alice", "bob"}, candidates)
+}
+
+func TestCompleteAfterDoubleDash(t *testing.T) {
+	var args struct {
+		Tags []string `choices:"a|b"`
+		User string   `arg:"positional" choices:"alice|bob"`
+	}
+	p, err := NewParser(Config{}, &args)
+	require.NoError(t, err)
+
+	candidates, _ := p.Complete([]string{"--tags", "a", ""})
+	assert.Equal(t, []string{"a", "b"}, candidates)
+
+	candidates, _ = p.Complete([]string{"--tags", "a", "--", ""})
+	assert.Equal(t, []string{"alice", "bob"}, candidates)
+
+	candidates, _ = p.Complete([]string{"--tags", "--"})
+	assert.Equal(t, []string{"--tags", "--help"}, candidates)
+}
+
+func TestCompleteAbbreviationsAndNegation(t *testing.T) {
+	var args struct {
+		Color bool   `arg:"negatable"`
+		Level string `choices:"debug|info"`
+		User  string `arg:"positional" choices:"alice|bob"`
+	}
+	p, err := NewParser(Config{AllowAbbreviations: true}, &args)
+	require.NoError(t, err)
+
+	candidates, _ := p.Complete([]string{"--lev", ""})
+	assert.Equal(t, []string{"debug", "info"}, candidates)
+
+	candidates, _ = p.Complete([]string{"--lev=i"})
+	assert.Equal(t, []string{"--lev=info"}, candidates)
+
+	candidates, _ = p.Complete([]string{"--no-color", ""})
+	assert.Equal(t, []string{"alice", "bob"}, candidates)
+}
+
+func TestCompleteDirectives(t *testing.T) {
+	_, directive := complete(t, "--workdir", "")
+	assert.Equal(t, CompleteDirs, directive)
+
+	_, directive = complete(t, "--count", "")
+	assert.Equal(t, CompleteNoFiles, directive)
+
+	_, directive = complete(t, "logs", "a", "")
+	assert.Equal(t, CompleteFiles, directive)
+}
+
+func TestCompleteWithCompleterType(t *testing.T) {
+	candidates, directive := complete(t, "deploy", "-c", "prod")
+	assert.Equal(t, []string{"prod-east", "prod-west"}, candidates)
+	assert.Equal(t, CompleteNoFiles, directive)
+}
+
+func TestCompleteWithStructMethod(t *testing.T) {
+	candidates, _ := complete(t, "deploy", "--namespace", "")
+	assert.Equal(t, []string{"default", "kube-system"}, candidates)
+
+	// the cluster given earlier is parsed before completing
+	candidates, _ = complete(t, "deploy", "--cluster", "staging", "--namespace", "")
+	assert.Equal(t, []string{"scratch"}, candidates)
+
+	candidates, _ = complete(t, "deploy", "--namespace", "default", "api", "w")
+	assert.Equal(t, []string{"web", "worker"}, candidates)
+}
+
+type completeValidated struct {
+	Name  string `arg:"required"`
+	Level string `choices:"debug|info"`
+	calls int
+}
+
+func (c *completeValidated) Validate() error {
+	c.calls++
+	return nil
+}
+
+func TestCompleteSkipsValidation(t *testing.T) {
+	var args completeValidated
+	p, err := NewParser(Config{}, &args)
+	require.NoError(t, err)
+
+	candidates, _ := p.Complete([]string{"--level", ""})
+	assert.Equal(t, []string{"debug", "info"}, candidates)
+	candidates, _ = p.Complete([]string{"--name", "x", "--level", ""})
+	assert.Equal(t, []string{"debug", "info"}, candidates)
+	assert.Equal(t, 0, args.calls)
+
+	// parsing afterwards still checks everything
+	err = p.Parse([]string{"--level", "info"})
+	assert.EqualError(t, err, "NAME is required")
+	err = p.Parse([]string{"--name", "x"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, args.calls)
+}
+
+func TestMustParseComplete(t *testing.T) {
+	originalArgs := os.Args
+	defer func() {
+		os.Args = originalArgs
+	}()
+	os.Args = []string{"example", "__complete", "deploy", "--cluster", "s"}
+
+	var exitCode int
+	var stdout bytes.Buffer
+	exit := func(code int) { exitCode = code }
+
+	var args completeArgs
+	mustParse(Config{Out: &stdout, Exit: exit, CompletionFlag: true}, &args)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "staging\n:nofiles\n", stdout.String())
+}
+
+func TestCompleteNotEnabled(t *testing.T) {
+	var args completeArgs
+	err := parse("__complete", &args)
+	assert.EqualError(t, err, "invalid subcommand: __complete")
+}
+
+func TestCompletionScriptsUseComplete(t *testing.T) {
+	var args completeArgs
+	p, err := NewParser(Config{Program: "example", CompletionFlag: true}, &args)
+	require.NoError(t, err)
+
+	for _, shell := range completionShells {
+		var b bytes.Buffer
+		require.NoError(t, p.WriteCompletion(&b, shell))
+		assert.Contains(t, b.String(), "__complete", shell)
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteCompletion(&b, "bash"))
+	script := b.String()
+	assert.Contains(t, script, "--cluster|-c) _example_dynamic; return ;;\n")
+	assert.Contains(t, script, "--namespace) _example_dynamic; return ;;\n")
+	assert.Contains(t, script, "--level) COMPREPLY=($(compgen -W 'debug info' -- \"${cur}\")); return ;;\n")
+	assert.Equal(t, 1, strings.Count(script, "else\n                _example_dynamic\n"))
+}
@@ -0,0 +1,538 @@
+package arg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionShells are the shells for which WriteCompletion can write a script
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionCommand is a command as seen by the completion scripts
+type completionCommand struct {
+	id          string             // an identifier for this command that is safe to use in scripts
+	words       []string           // the words that select this command: its name and aliases
+	parent      *completionCommand // the command above this one, or nil for the top-level command
+	options     []*spec            // the options that can be given to this command, including builtins
+	subcommands []*command         // the visible subcommands of this command
+	positionals bool               // if true, this command takes positional arguments
+	dynamic     bool               // if true, the positional arguments are completed by the program
+}
+
+// completionCommands flattens the tree of visible commands, parents first
+func (p *Parser) completionCommands() []*completionCommand {
+	var cmds []*completionCommand
+	var visit func(cmd *command, parent *completionCommand)
+	visit = func(cmd *command, parent *completionCommand) {
+		c := &completionCommand{
+			id:      shellIdentifier(cmd.name),
+			words:   append([]string{cmd.name}, cmd.aliases...),
+			parent:  parent,
+			options: p.completionOptions(cmd),
+		}
+		if parent != nil {
+			c.id = parent.id + "__" + c.id
+		}
+		for _, spec := range cmd.specs {
+			if spec.positional {
+				c.positionals = true
+				c.dynamic = c.dynamic || p.completesDynamically(spec)
+			}
+		}
+		for _, subcmd := range cmd.subcommands {
+			if !subcmd.hidden {
+				c.subcommands = append(c.subcommands, subcmd)
+			}
+		}
+
+		cmds = append(cmds, c)
+		for _, subcmd := range c.subcommands {
+			visit(subcmd, c)
+		}
+	}
+	visit(p.cmd, nil)
+	return cmds
+}
+
+// completionOptions gets the visible options that can be given to a command,
+// which are its own options, the options of its ancestors unless subcommands are
+// strict, and the builtin --help and --version
+func (p *Parser) completionOptions(cmd *command) []*spec {
+	var options []*spec
+	var hasVersionOption bool
+	for c := cmd; c != nil; c = c.parent {
+		for _, spec := range c.specs {
+			if spec.long == "version" {
+				hasVersionOption = true
+			}
+			if spec.hidden || spec.positional || (spec.long == "" && spec.short == "") {
+				continue
+			}
+			if c == cmd || !p.config.StrictSubcommands {
+				options = append(options, spec)
+			}
+		}
+	}
+
+	options = append(options, &spec{
+		cardinality: zero,
+		long:        "help",
+		short:       "h",
+		help:        "display this help and exit",
+	})
+	if !hasVersionOption && p.version != "" {
+		options = append(options, &spec{
+			cardinality: zero,
+			long:        "version",
+			help:        "display version and exit",
+		})
+	}
+	return options
+}
+
+// completesDynamically returns true if the completion scripts should ask the
+// program for the values of an option through the builtin __complete
+func (p *Parser) completesDynamically(spec *spec) bool {
+	return p.config.CompletionFlag && p.completerFor(spec) != nil
+}
+
+// hasDynamicCompletion returns true if any of the commands has an option or
+// positional argument whose values are completed by the program
+func (p *Parser) hasDynamicCompletion(cmds []*completionCommand) bool {
+	for _, c := range cmds {
+		if c.dynamic {
+			return true
+		}
+		for _, spec := range c.options {
+			if p.completesDynamically(spec) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// optionWords gets the forms in which an option can appear on the command line
+func optionWords(spec *spec) []string {
+	var words []string
+	if spec.long != "" {
+		words = append(words, "--"+spec.long)
+		if spec.negatable {
+			words = append(words, "--no-"+spec.long)
+		}
+	}
+	if spec.short != "" {
+		words = append(words, "-"+spec.short)
+	}
+	return words
+}
+
+// takesValue returns true if an option is always followed by a value
+func takesValue(spec *spec) bool {
+	return spec.cardinality != zero && !spec.optionalValue
+}
+
+// valueCount gets the largest number of values that follow an option that takes
+// a value, or -1 if it takes values until the next option, mirroring process
+func valueCount(spec *spec) int {
+	switch {
+	case spec.maxValues > 0:
+		return spec.maxValues
+	case spec.cardinality == multiple && !spec.separate:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// valueWords gets the forms of the options of a command that take a value,
+// grouped by the number of values that follow them, in the order in which the
+// options appear
+func (c *completionCommand) valueWords() (counts []int, words map[int][]string) {
+	words = make(map[int][]string)
+	for _, spec := range c.options {
+		if !takesValue(spec) {
+			continue
+		}
+		n := valueCount(spec)
+		if _, seen := words[n]; !seen {
+			counts = append(counts, n)
+		}
+		words[n] = append(words[n], optionWords(spec)...)
+	}
+	return counts, words
+}
+
+// shellIdentifier replaces characters that cannot appear in a shell function
+// name with underscores
+func shellIdentifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shellQuote quotes a string for bash and zsh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// shellWordList quotes a list of words for use with compgen -W, which splits the
+// list into words and removes quotes and backslashes
+func shellWordList(words []string) string {
+	escaped := make([]string, len(words))
+	for i, word := range words {
+		var b strings.Builder
+		for _, r := range word {
+			if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,:=+@/", r) {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		}
+		escaped[i] = b.String()
+	}
+	return shellQuote(strings.Join(escaped, " "))
+}
+
+// fishQuote quotes a string for fish
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "'", `\'`)
+	return "'" + s + "'"
+}
+
+// WriteCompletion writes a script that completes the options and subcommands of
+// the program in the given shell, which must be "bash", "zsh", or "fish". Options
+// take file names as values unless they have a fixed set of choices, and
+// positional arguments are completed as file names. Hidden options and
+// subcommands are left out. If Config.CompletionFlag is set then the values of
+// fields with completers are completed by running the program with the builtin
+// __complete; see Complete.
+func (p *Parser) WriteCompletion(w io.Writer, shell string) error {
+	if err := checkCompletionShell(shell); err != nil {
+		return err
+	}
+	switch shell {
+	case "bash":
+		p.writeBashCompletion(w)
+	case "zsh":
+		p.writeZshCompletion(w)
+	case "fish":
+		p.writeFishCompletion(w)
+	}
+	return nil
+}
+
+// checkCompletionShell returns an error if there is no completion script for a shell
+func checkCompletionShell(shell string) error {
+	if !contains(completionShells, shell) {
+		return fmt.Errorf("unsupported shell %q (choose from %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// writeCommandWalk writes the part of a bash or zsh script that finds the
+// selected command by looking at each word before the one being completed,
+// skipping the values of options. As in process, values end at the next word
+// that looks like an option, and skip is negative for options that take values
+// until then.
+func writeCommandWalk(w io.Writer, cmds []*completionCommand) {
+	fmt.Fprintf(w, "        if ((skip != 0)) && [[ \"${word}\" != -?* ]]; then\n")
+	fmt.Fprintf(w, "            ((skip--))\n")
+	fmt.Fprintf(w, "            continue\n")
+	fmt.Fprintf(w, "        fi\n")
+	fmt.Fprintf(w, "        skip=0\n")
+	fmt.Fprintf(w, "        case \"${cmd},${word}\" in\n")
+	for _, c := range cmds {
+		counts, values := c.valueWords()
+		for _, n := range counts {
+			var patterns []string
+			for _, word := range values[n] {
+				patterns = append(patterns, shellQuote(c.id+","+word))
+			}
+			fmt.Fprintf(w, "            %s) skip=%d ;;\n", strings.Join(patterns, "|"), n)
+		}
+		if c.parent == nil {
+			continue
+		}
+		var patterns []string
+		for _, word := range c.words {
+			patterns = append(patterns, shellQuote(c.parent.id+","+word))
+		}
+		fmt.Fprintf(w, "            %s) cmd=%s ;;\n", strings.Join(patterns, "|"), c.id)
+	}
+	fmt.Fprintf(w, "        esac\n")
+}
+
+func (p *Parser) writeBashCompletion(w io.Writer) {
+	cmds := p.completionCommands()
+	fn := "_" + shellIdentifier(p.cmd.name) + "_completion"
+	dynamic := "_" + shellIdentifier(p.cmd.name) + "_dynamic"
+
+	fmt.Fprintf(w, "# bash completion for %s\n\n", p.cmd.name)
+	if p.hasDynamicCompletion(cmds) {
+		// the last line of the output of __complete is the directive
+		fmt.Fprintf(w, "%s() {\n", dynamic)
+		fmt.Fprintf(w, "    local out directive\n")
+		fmt.Fprintf(w, "    out=\"$(\"${COMP_WORDS[0]}\" __complete \"${COMP_WORDS[@]:1:COMP_CWORD}\" 2>/dev/null)\"\n")
+		fmt.Fprintf(w, "    directive=\"${out##*:}\"\n")
+		fmt.Fprintf(w, "    out=\"${out%%:*}\"\n")
+		fmt.Fprintf(w, "    COMPREPLY=($(compgen -W \"${out}\" -- \"${cur}\"))\n")
+		fmt.Fprintf(w, "    case \"${directive}\" in\n")
+		fmt.Fprintf(w, "        files) COMPREPLY+=($(compgen -f -- \"${cur}\")) ;;\n")
+		fmt.Fprintf(w, "        dirs) COMPREPLY+=($(compgen -d -- \"${cur}\")) ;;\n")
+		fmt.Fprintf(w, "    esac\n")
+		fmt.Fprintf(w, "}\n\n")
+	}
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    local cmd=%s word i skip=0\n", cmds[0].id)
+	fmt.Fprintf(w, "    COMPREPLY=()\n")
+	fmt.Fprintf(w, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "        word=\"${COMP_WORDS[i]}\"\n")
+	writeCommandWalk(w, cmds)
+	fmt.Fprintf(w, "    done\n\n")
+
+	fmt.Fprintf(w, "    case \"${cmd}\" in\n")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "        %s)\n", c.id)
+		fmt.Fprintf(w, "            case \"${prev}\" in\n")
+		for _, spec := range c.options {
+			if !takesValue(spec) {
+				continue
+			}
+			words := strings.Join(optionWords(spec), "|")
+			if p.completesDynamically(spec) {
+				fmt.Fprintf(w, "                %s) %s; return ;;\n", words, dynamic)
+			} else if len(spec.choices) > 0 {
+				fmt.Fprintf(w, "                %s) COMPREPLY=($(compgen -W %s -- \"${cur}\")); return ;;\n", words, shellWordList(spec.choices))
+			} else {
+				fmt.Fprintf(w, "                %s) COMPREPLY=($(compgen -f -- \"${cur}\")); return ;;\n", words)
+			}
+		}
+		fmt.Fprintf(w, "            esac\n")
+
+		var options []string
+		for _, spec := range c.options {
+			options = append(options, optionWords(spec)...)
+		}
+		fmt.Fprintf(w, "            if [[ \"${cur}\" == -* ]]; then\n")
+		fmt.Fprintf(w, "                COMPREPLY=($(compgen -W %s -- \"${cur}\"))\n", shellWordList(options))
+		switch {
+		case len(c.subcommands) > 0:
+			var names []string
+			for _, subcmd := range c.subcommands {
+				names = append(names, subcmd.name)
+			}
+			fmt.Fprintf(w, "            else\n")
+			fmt.Fprintf(w, "                COMPREPLY=($(compgen -W %s -- \"${cur}\"))\n", shellWordList(names))
+		case c.dynamic:
+			fmt.Fprintf(w, "            else\n")
+			fmt.Fprintf(w, "                %s\n", dynamic)
+		case c.positionals:
+			fmt.Fprintf(w, "            else\n")
+			fmt.Fprintf(w, "                COMPREPLY=($(compgen -f -- \"${cur}\"))\n")
+		}
+		fmt.Fprintf(w, "            fi\n")
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -o filenames -F %s %s\n", fn, shellQuote(p.cmd.name))
+}
+
+func (p *Parser) writeZshCompletion(w io.Writer) {
+	cmds := p.completionCommands()
+	fn := "_" + shellIdentifier(p.cmd.name)
+	dynamic := "_" + shellIdentifier(p.cmd.name) + "_dynamic"
+
+	fmt.Fprintf(w, "#compdef %s\n\n", p.cmd.name)
+	if p.hasDynamicCompletion(cmds) {
+		// the last line of the output of __complete is the directive
+		fmt.Fprintf(w, "%s() {\n", dynamic)
+		fmt.Fprintf(w, "    local out directive\n")
+		fmt.Fprintf(w, "    out=\"$(\"${words[1]}\" __complete \"${(@)words[2,CURRENT]}\" 2>/dev/null)\"\n")
+		fmt.Fprintf(w, "    directive=\"${out##*:}\"\n")
+		fmt.Fprintf(w, "    out=\"${out%%:*}\"\n")
+		fmt.Fprintf(w, "    compadd -- ${(f)out}\n")
+		fmt.Fprintf(w, "    case \"${directive}\" in\n")
+		fmt.Fprintf(w, "        files) _files ;;\n")
+		fmt.Fprintf(w, "        dirs) _files -/ ;;\n")
+		fmt.Fprintf(w, "    esac\n")
+		fmt.Fprintf(w, "}\n\n")
+	}
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local cur=\"${words[CURRENT]}\" prev=\"${words[CURRENT-1]}\"\n")
+	fmt.Fprintf(w, "    local cmd=%s word i skip=0\n", cmds[0].id)
+	fmt.Fprintf(w, "    for ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprintf(w, "        word=\"${words[i]}\"\n")
+	writeCommandWalk(w, cmds)
+	fmt.Fprintf(w, "    done\n\n")
+
+	fmt.Fprintf(w, "    case \"${cmd}\" in\n")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "        %s)\n", c.id)
+		fmt.Fprintf(w, "            case \"${prev}\" in\n")
+		for _, spec := range c.options {
+			if !takesValue(spec) {
+				continue
+			}
+			words := strings.Join(optionWords(spec), "|")
+			if p.completesDynamically(spec) {
+				fmt.Fprintf(w, "                %s) %s; return ;;\n", words, dynamic)
+			} else if len(spec.choices) > 0 {
+				var quoted []string
+				for _, choice := range spec.choices {
+					quoted = append(quoted, shellQuote(choice))
+				}
+				fmt.Fprintf(w, "                %s) compadd -- %s; return ;;\n", words, strings.Join(quoted, " "))
+			} else {
+				fmt.Fprintf(w, "                %s) _files; return ;;\n", words)
+			}
+		}
+		fmt.Fprintf(w, "            esac\n")
+
+		fmt.Fprintf(w, "            if [[ \"${cur}\" == -* ]]; then\n")
+		fmt.Fprintf(w, "                local -a options=(\n")
+		for _, spec := range c.options {
+			for _, word := range optionWords(spec) {
+				fmt.Fprintf(w, "                    %s\n", shellQuote(strings.ReplaceAll(word, ":", `\:`)+":"+spec.help))
+			}
+		}
+		fmt.Fprintf(w, "                )\n")
+		fmt.Fprintf(w, "                _describe option options\n")
+		switch {
+		case len(c.subcommands) > 0:
+			fmt.Fprintf(w, "            else\n")
+			fmt.Fprintf(w, "                local -a commands=(\n")
+			for _, subcmd := range c.subcommands {
+				fmt.Fprintf(w, "                    %s\n", shellQuote(strings.ReplaceAll(subcmd.name, ":", `\:`)+":"+subcmd.help))
+			}
+			fmt.Fprintf(w, "                )\n")
+			fmt.Fprintf(w, "                _describe command commands\n")
+		case c.dynamic:
+			fmt.Fprintf(w, "            else\n")
+			fmt.Fprintf(w, "                %s\n", dynamic)
+		case c.positionals:
+			fmt.Fprintf(w, "            else\n")
+			fmt.Fprintf(w, "                _files\n")
+		}
+		fmt.Fprintf(w, "            fi\n")
+		fmt.Fprintf(w, "            ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "if [[ \"${funcstack[1]}\" == %s ]]; then\n", fn)
+	fmt.Fprintf(w, "    %s \"$@\"\n", fn)
+	fmt.Fprintf(w, "else\n")
+	fmt.Fprintf(w, "    compdef %s %s\n", fn, shellQuote(p.cmd.name))
+	fmt.Fprintf(w, "fi\n")
+}
+
+func (p *Parser) writeFishCompletion(w io.Writer) {
+	cmds := p.completionCommands()
+	prog := fishQuote(p.cmd.name)
+	fn := "__" + shellIdentifier(p.cmd.name) + "_command"
+
+	// the helper function prints the identifier of the selected command
+	fmt.Fprintf(w, "# fish completion for %s\n\n", p.cmd.name)
+	fmt.Fprintf(w, "function %s\n", fn)
+	fmt.Fprintf(w, "    set -l words (commandline -opc)\n")
+	fmt.Fprintf(w, "    set -l cmd %s\n", cmds[0].id)
+	fmt.Fprintf(w, "    set -l skip 0\n")
+	fmt.Fprintf(w, "    for word in $words[2..-1]\n")
+	fmt.Fprintf(w, "        if test $skip -ne 0; and not string match -q -- '-?*' $word\n")
+	fmt.Fprintf(w, "            test $skip -gt 0; and set skip (math $skip - 1)\n")
+	fmt.Fprintf(w, "            continue\n")
+	fmt.Fprintf(w, "        end\n")
+	fmt.Fprintf(w, "        set skip 0\n")
+	fmt.Fprintf(w, "        switch \"$cmd,$word\"\n")
+	for _, c := range cmds {
+		counts, values := c.valueWords()
+		for _, n := range counts {
+			var patterns []string
+			for _, word := range values[n] {
+				patterns = append(patterns, fishQuote(c.id+","+word))
+			}
+			fmt.Fprintf(w, "            case %s\n", strings.Join(patterns, " "))
+			fmt.Fprintf(w, "                set skip %d\n", n)
+		}
+		if c.parent == nil {
+			continue
+		}
+		var patterns []string
+		for _, word := range c.words {
+			patterns = append(patterns, fishQuote(c.parent.id+","+word))
+		}
+		fmt.Fprintf(w, "            case %s\n", strings.Join(patterns, " "))
+		fmt.Fprintf(w, "                set cmd %s\n", c.id)
+	}
+	fmt.Fprintf(w, "        end\n")
+	fmt.Fprintf(w, "    end\n")
+	fmt.Fprintf(w, "    echo $cmd\n")
+	fmt.Fprintf(w, "end\n\n")
+
+	dynamic := "__" + shellIdentifier(p.cmd.name) + "_dynamic"
+	if p.hasDynamicCompletion(cmds) {
+		// the last line of the output of __complete is the directive
+		fmt.Fprintf(w, "function %s\n", dynamic)
+		fmt.Fprintf(w, "    set -l words (commandline -opc)\n")
+		fmt.Fprintf(w, "    set -l cur (commandline -ct)\n")
+		fmt.Fprintf(w, "    set -l out ($words[1] __complete $words[2..-1] $cur 2>/dev/null)\n")
+		fmt.Fprintf(w, "    if test (count $out) -gt 1\n")
+		fmt.Fprintf(w, "        printf '%%s\\n' $out[1..-2]\n")
+		fmt.Fprintf(w, "    end\n")
+		fmt.Fprintf(w, "    switch \"$out[-1]\"\n")
+		fmt.Fprintf(w, "        case :files\n")
+		fmt.Fprintf(w, "            __fish_complete_path $cur\n")
+		fmt.Fprintf(w, "        case :dirs\n")
+		fmt.Fprintf(w, "            __fish_complete_directories $cur\n")
+		fmt.Fprintf(w, "    end\n")
+		fmt.Fprintf(w, "end\n\n")
+	}
+
+	fmt.Fprintf(w, "complete -c %s -f\n", prog)
+	for _, c := range cmds {
+		cond := fmt.Sprintf("-n 'test (%s) = %s'", fn, c.id)
+		for _, spec := range c.options {
+			line := fmt.Sprintf("complete -c %s %s", prog, cond)
+			if spec.long != "" {
+				line += " -l " + fishQuote(spec.long)
+			}
+			if spec.short != "" {
+				line += " -s " + fishQuote(spec.short)
+			}
+			if takesValue(spec) {
+				if p.completesDynamically(spec) {
+					line += " -x -a " + fishQuote("("+dynamic+")")
+				} else if len(spec.choices) > 0 {
+					line += " -x -a " + fishQuote(strings.Join(spec.choices, " "))
+				} else {
+					line += " -r -F"
+				}
+			}
+			if spec.help != "" {
+				line += " -d " + fishQuote(spec.help)
+			}
+			fmt.Fprintln(w, line)
+			if spec.negatable {
+				fmt.Fprintf(w, "complete -c %s %s -l %s\n", prog, cond, fishQuote("no-"+spec.long))
+			}
+		}
+		for _, subcmd := range c.subcommands {
+			line := fmt.Sprintf("complete -c %s %s -a %s", prog, cond, fishQuote(subcmd.name))
+			if subcmd.help != "" {
+				line += " -d " + fishQuote(subcmd.help)
+			}
+			fmt.Fprintln(w, line)
+		}
+		if c.dynamic {
+			fmt.Fprintf(w, "complete -c %s %s -a %s\n", prog, cond, fishQuote("("+dynamic+")"))
+		} else if c.positionals {
+			fmt.Fprintf(w, "complete -c %s %s -F\n", prog, cond)
+		}
+	}
+}
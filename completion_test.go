@@ -0,0 +1,159 @@
+package arg
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type completionAdd struct {
+	Name  string `arg:"positional"`
+	Fetch bool   `arg:"-f" help:"fetch after adding"`
+}
+
+type completionRemote struct {
+	Add    *completionAdd `arg:"subcommand:add" help:"add a remote"`
+	Secret *struct{}      `arg:"subcommand:secret,hidden"`
+}
+
+type completionArgs struct {
+	Verbose bool              `arg:"-v" help:"be verbose"`
+	Level   string            `choices:"debug|info"`
+	Out     string            `help:"output file"`
+	Token   string            `arg:"hidden"`
+	Remote  *completionRemote `arg:"subcommand:remote|rm" help:"manage remotes"`
+}
+
+func writeCompletion(t *testing.T, shell string) string {
+	var args completionArgs
+	p, err := NewParser(Config{Program: "my-prog"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteCompletion(&b, shell))
+	return b.String()
+}
+
+func TestWriteCompletionBash(t *testing.T) {
+	script := writeCompletion(t, "bash")
+	assert.Contains(t, script, "_my_prog_completion() {\n")
+	assert.Contains(t, script, "'my_prog,remote'|'my_prog,rm') cmd=my_prog__remote ;;\n")
+	assert.Contains(t, script, "'my_prog__remote,add') cmd=my_prog__remote__add ;;\n")
+	assert.Contains(t, script, "'my_prog,--level'|'my_prog,--out') skip=1 ;;\n")
+	assert.Contains(t, script, "--level) COMPREPLY=($(compgen -W 'debug info' -- \"${cur}\")); return ;;\n")
+	assert.Contains(t, script, "--out) COMPREPLY=($(compgen -f -- \"${cur}\")); return ;;\n")
+	assert.Contains(t, script, "COMPREPLY=($(compgen -W '--verbose -v --level --out --help -h' -- \"${cur}\"))\n")
+	assert.Contains(t, script, "COMPREPLY=($(compgen -W 'remote' -- \"${cur}\"))\n")
+	assert.Contains(t, script, "complete -o filenames -F _my_prog_completion 'my-prog'\n")
+	assert.NotContains(t, script, "token")
+	assert.NotContains(t, script, "secret")
+}
+
+func TestWriteCompletionZsh(t *testing.T) {
+	script := writeCompletion(t, "zsh")
+	assert.Contains(t, script, "#compdef my-prog\n")
+	assert.Contains(t, script, "'--verbose:be verbose'\n")
+	assert.Contains(t, script, "'remote:manage remotes'\n")
+	assert.Contains(t, script, "--level) compadd -- 'debug' 'info'; return ;;\n")
+	assert.Contains(t, script, "compdef _my_prog 'my-prog'\n")
+	assert.NotContains(t, script, "token")
+	assert.NotContains(t, script, "secret")
+}
+
+func TestWriteCompletionFish(t *testing.T) {
+	script := writeCompletion(t, "fish")
+	assert.Contains(t, script, "function __my_prog_command\n")
+	assert.Contains(t, script, "complete -c 'my-prog' -n 'test (__my_prog_command) = my_prog' -l 'verbose' -s 'v' -d 'be verbose'\n")
+	assert.Contains(t, script, "complete -c 'my-prog' -n 'test (__my_prog_command) = my_prog' -l 'level' -x -a 'debug info'\n")
+	assert.Contains(t, script, "complete -c 'my-prog' -n 'test (__my_prog_command) = my_prog__remote' -a 'add' -d 'add a remote'\n")
+	assert.Contains(t, script, "complete -c 'my-prog' -n 'test (__my_prog_command) = my_prog__remote__add' -F\n")
+	assert.NotContains(t, script, "token")
+	assert.NotContains(t, script, "secret")
+}
+
+func TestWriteCompletionMultipleValuesBeforeSubcommand(t *testing.T) {
+	var args struct {
+		Point [2]int
+		Tags  []string
+		Label []string  `arg:"separate"`
+		Sub   *struct{} `arg:"subcommand:sub"`
+	}
+	p, err := NewParser(Config{Program: "prog"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteCompletion(&b, "bash"))
+	script := b.String()
+	assert.Contains(t, script, "        if ((skip != 0)) && [[ \"${word}\" != -?* ]]; then\n")
+	assert.Contains(t, script, "'prog,--point') skip=2 ;;\n")
+	assert.Contains(t, script, "'prog,--tags') skip=-1 ;;\n")
+	assert.Contains(t, script, "'prog,--label') skip=1 ;;\n")
+	assert.Contains(t, script, "'prog,sub') cmd=prog__sub ;;\n")
+
+	b.Reset()
+	require.NoError(t, p.WriteCompletion(&b, "fish"))
+	script = b.String()
+	assert.Contains(t, script, "        if test $skip -ne 0; and not string match -q -- '-?*' $word\n")
+	assert.Contains(t, script, "            case 'prog,--point'\n                set skip 2\n")
+	assert.Contains(t, script, "            case 'prog,--tags'\n                set skip -1\n")
+	assert.Contains(t, script, "            case 'prog,--label'\n                set skip 1\n")
+}
+
+func TestWriteCompletionUnsupportedShell(t *testing.T) {
+	var args completionArgs
+	p, err := NewParser(Config{}, &args)
+	require.NoError(t, err)
+	err = p.WriteCompletion(&bytes.Buffer{}, "tcsh")
+	assert.EqualError(t, err, `unsupported shell "tcsh" (choose from bash, zsh, fish)`)
+}
+
+func TestCompletionFlag(t *testing.T) {
+	var args completionArgs
+	p, err := NewParser(Config{CompletionFlag: true}, &args)
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrCompletion, p.Parse([]string{"--completion", "zsh"}))
+	assert.Equal(t, "zsh", p.completionShell)
+
+	assert.Equal(t, ErrCompletion, p.Parse([]string{"--completion=fish"}))
+	assert.Equal(t, "fish", p.completionShell)
+
+	err = p.Parse([]string{"--completion"})
+	assert.EqualError(t, err, "missing value for --completion")
+
+	err = p.Parse([]string{"--completion", "tcsh"})
+	assert.EqualError(t, err, `unsupported shell "tcsh" (choose from bash, zsh, fish)`)
+}
+
+func TestCompletionFlagNotEnabled(t *testing.T) {
+	var args completionArgs
+	err := parse("--completion bash", &args)
+	assert.EqualError(t, err, "unknown argument --completion")
+}
+
+func TestCompletionFlagWithAbbreviations(t *testing.T) {
+	var args completionArgs
+	p, err := NewParser(Config{CompletionFlag: true, AllowAbbreviations: true}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, ErrCompletion, p.Parse([]string{"--compl", "bash"}))
+}
+
+func TestMustParseCompletionFlag(t *testing.T) {
+	originalArgs := os.Args
+	defer func() {
+		os.Args = originalArgs
+	}()
+	os.Args = []string{"my-prog", "--completion", "bash"}
+
+	var exitCode int
+	var stdout bytes.Buffer
+	exit := func(code int) { exitCode = code }
+
+	var args completionArgs
+	mustParse(Config{Out: &stdout, Exit: exit, CompletionFlag: true}, &args)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, stdout.String(), "complete -o filenames -F _my_prog_completion 'my-prog'\n")
+}
@@ -0,0 +1,210 @@
+package arg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// configFile holds the parsed contents of a JSON config file
+type configFile struct {
+	path string
+	root map[string]interface{}
+}
+
+// loadConfigFile reads and parses a JSON config file. The top level of the file
+// must be an object.
+func loadConfigFile(path string) (*configFile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &ConfigFileError{File: path, Err: err}
+	}
+
+	var root map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&root); err != nil {
+		return nil, &ConfigFileError{File: path, Err: err}
+	}
+	return &configFile{path: path, root: root}, nil
+}
+
+// section finds the object nested under the given sequence of subcommand names,
+// or returns nil if there is no such object
+func (f *configFile) section(names []string) map[string]interface{} {
+	obj := f.root
+	for _, name := range names {
+		child, ok := obj[name].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		obj = child
+	}
+	return obj
+}
+
+// configValues converts a value from a JSON config file into strings that can be
+// parsed in the same way as command line arguments
+func configValues(raw interface{}, spec *spec) ([]string, error) {
+	switch raw := raw.(type) {
+	case []interface{}:
+		if spec.cardinality != multiple {
+			return nil, fmt.Errorf("expected a single value but got a list")
+		}
+		values := make([]string, len(raw))
+		for i, elem := range raw {
+			s, err := configScalar(elem)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = s
+		}
+		return values, nil
+	case map[string]interface{}:
+		t := spec.field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Map {
+			return nil, fmt.Errorf("expected a value but got an object")
+		}
+		keys := make([]string, 0, len(raw))
+		for k := range raw {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		values := make([]string, len(keys))
+		for i, k := range keys {
+			s, err := configScalar(raw[k])
+			if err != nil {
+				return nil, err
+			}
+			values[i] = k + "=" + s
+		}
+		return values, nil
+	default:
+		s, err := configScalar(raw)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+}
+
+// configScalar converts a single JSON string, number, or boolean to a string
+func configScalar(raw interface{}) (string, error) {
+	switch raw := raw.(type) {
+	case string:
+		return raw, nil
+	case json.Number:
+		return raw.String(), nil
+	case bool:
+		if raw {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		return "", fmt.Errorf("expected a string, number, or boolean")
+	}
+}
+
+// configFilePath gets the path given by an option with the "config" tag, or the
+// empty string if there is no such path
+func (p *Parser) configFilePath(spec *spec) string {
+	v := p.val(spec.dest)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	return v.String()
+}
+
+// loadConfigFiles reads the files listed in Config.ConfigFiles, skipping those that
+// do not exist, followed by the file named by an active option with the "config"
+// tag, which must exist. Later files take precedence over earlier ones.
+func (p *Parser) loadConfigFiles(active []*spec) ([]*configFile, error) {
+	var files []*configFile
+	for _, path := range p.config.ConfigFiles {
+		f, err := loadConfigFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	for _, spec := range active {
+		if !spec.configFile {
+			continue
+		}
+		if path := p.configFilePath(spec); path != "" {
+			f, err := loadConfigFile(path)
+			if err != nil {
+				return files, err
+			}
+			files = append(files, f)
+		}
+		break
+	}
+	return files, nil
+}
+
+// configFileSource reads values from config files. Options for subcommands are
+// read from objects nested under the name of each subcommand.
+type configFileSource struct {
+	files []*configFile
+}
+
+func (s configFileSource) capture(p *Parser, spec *spec) (Origin, bool, error) {
+	if spec.configFile || spec.configKey == "" {
+		return Origin{}, false, nil
+	}
+
+	// look through the files starting with the one that takes precedence
+	for i := len(s.files) - 1; i >= 0; i-- {
+		raw, found := s.files[i].section(spec.commandPath)[spec.configKey]
+		if !found || raw == nil {
+			continue
+		}
+		origin := Origin{
+			Kind:  OriginConfigFile,
+			Index: -1,
+			File:  s.files[i].path,
+			Key:   strings.Join(append(append([]string{}, spec.commandPath...), spec.configKey), "."),
+		}
+		values, err := p.setFromConfig(spec, raw)
+		origin.Value = strings.Join(values, " ")
+		if err != nil {
+			return origin, true, &ConfigFileError{File: origin.File, Key: origin.Key, Err: err}
+		}
+		return origin, true, nil
+	}
+	return Origin{}, false, nil
+}
+
+// setFromConfig stores a value from a config file in the field for a spec, and
+// returns the value in string form
+func (p *Parser) setFromConfig(spec *spec, raw interface{}) ([]string, error) {
+	values, err := configValues(raw, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.cardinality == multiple {
+		if spec.maxValues > 0 && (len(values) < spec.minValues || len(values) > spec.maxValues) {
+			return values, p.countError(spec, "", spec.minValues, spec.maxValues, len(values))
+		}
+		return values, p.setMultiple(spec, values, true)
+	}
+	return values, p.setScalar(spec, values[0])
+}
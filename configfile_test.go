@@ -0,0 +1,230 @@
+package arg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"name": "alice",
+		"port": 8080,
+		"verbose": true,
+		"tags": ["a", "b"],
+		"labels": {"x": "1", "y": 2},
+		"timeout-secs": 30
+	}`)
+
+	var args struct {
+		Name    string
+		Port    int
+		Verbose bool
+		Tags    []string
+		Labels  map[string]int
+		Timeout int `config:"timeout-secs"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", args.Name)
+	assert.Equal(t, 8080, args.Port)
+	assert.True(t, args.Verbose)
+	assert.Equal(t, []string{"a", "b"}, args.Tags)
+	assert.Equal(t, map[string]int{"x": 1, "y": 2}, args.Labels)
+	assert.Equal(t, 30, args.Timeout)
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{"a": "file", "b": "file", "c": "file"}`)
+
+	var args struct {
+		A string
+		B string `arg:"env:CONFIG_PRECEDENCE_B"`
+		C string `default:"default"`
+		D string `default:"default"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "--a cli", []string{"CONFIG_PRECEDENCE_B=env"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "cli", args.A)
+	assert.Equal(t, "env", args.B)
+	assert.Equal(t, "file", args.C)
+	assert.Equal(t, "default", args.D)
+}
+
+func TestConfigFileLaterFilesTakePrecedence(t *testing.T) {
+	first := writeConfigFile(t, `{"a": "first", "b": "first"}`)
+	second := writeConfigFile(t, `{"b": "second"}`)
+
+	var args struct {
+		A string
+		B string
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{first, second}}, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "first", args.A)
+	assert.Equal(t, "second", args.B)
+}
+
+func TestConfigFileMissingIsSkipped(t *testing.T) {
+	var args struct {
+		A string
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{"does-not-exist.json"}}, "", nil, &args)
+	require.NoError(t, err)
+}
+
+func TestConfigFileSatisfiesRequired(t *testing.T) {
+	path := writeConfigFile(t, `{"name": "alice"}`)
+
+	var args struct {
+		Name string `arg:"required"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", args.Name)
+}
+
+func TestConfigFileExcludedField(t *testing.T) {
+	path := writeConfigFile(t, `{"secret": "x"}`)
+
+	var args struct {
+		Secret string `config:"-"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "", args.Secret)
+}
+
+func TestConfigFileOption(t *testing.T) {
+	path := writeConfigFile(t, `{"name": "alice"}`)
+
+	var args struct {
+		Config string `arg:"config"`
+		Name   string
+	}
+	_, err := parseWithEnv(Config{}, "--config "+path, nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", args.Name)
+}
+
+func TestConfigFileOptionDefault(t *testing.T) {
+	path := writeConfigFile(t, `{"name": "alice"}`)
+
+	var args struct {
+		Config *string `arg:"config"`
+		Name   string
+	}
+	args.Config = &path
+	_, err := parseWithEnv(Config{}, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", args.Name)
+}
+
+func TestConfigFileOptionMissing(t *testing.T) {
+	var args struct {
+		Config string `arg:"config"`
+	}
+	_, err := parseWithEnv(Config{}, "--config does-not-exist.json", nil, &args)
+
+	var e *ConfigFileError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "does-not-exist.json", e.File)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestConfigFileOptionNotString(t *testing.T) {
+	var args struct {
+		Config int `arg:"config"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
+func TestConfigFileSubcommandSection(t *testing.T) {
+	path := writeConfigFile(t, `{"verbose": true, "get": {"force": true, "item": {"depth": 3}}}`)
+
+	type itemCmd struct {
+		Depth int
+	}
+	type getCmd struct {
+		Force bool
+		Item  *itemCmd `arg:"subcommand"`
+	}
+	var args struct {
+		Verbose bool
+		Get     *getCmd `arg:"subcommand"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "get item", nil, &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	require.NotNil(t, args.Get)
+	assert.True(t, args.Get.Force)
+	require.NotNil(t, args.Get.Item)
+	assert.Equal(t, 3, args.Get.Item.Depth)
+}
+
+func TestConfigFileInvalidValue(t *testing.T) {
+	path := writeConfigFile(t, `{"get": {"count": "many"}}`)
+
+	type getCmd struct {
+		Count int
+	}
+	var args struct {
+		Get *getCmd `arg:"subcommand"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "get", nil, &args)
+
+	var e *ConfigFileError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, path, e.File)
+	assert.Equal(t, "get.count", e.Key)
+	assert.EqualError(t, err, path+`: error processing get.count: strconv.ParseInt: parsing "many": invalid syntax`)
+}
+
+func TestConfigFileListForSingleValue(t *testing.T) {
+	path := writeConfigFile(t, `{"name": ["a", "b"]}`)
+
+	var args struct {
+		Name string
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "", nil, &args)
+	assert.EqualError(t, err, path+": error processing name: expected a single value but got a list")
+}
+
+func TestConfigFileTooManyValues(t *testing.T) {
+	path := writeConfigFile(t, `{"point": [1, 2, 3]}`)
+
+	var args struct {
+		Point []int `nargs:"2"`
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "", nil, &args)
+	assert.EqualError(t, err, path+": error processing point: requires 2 values but got 3")
+
+	var e *TooManyValuesError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "point", e.Long)
+	assert.Equal(t, 3, e.Got)
+}
+
+func TestConfigFileSyntaxError(t *testing.T) {
+	path := writeConfigFile(t, `{"name": `)
+
+	var args struct {
+		Name string
+	}
+	_, err := parseWithEnv(Config{ConfigFiles: []string{path}}, "", nil, &args)
+
+	var e *ConfigFileError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "", e.Key)
+}
@@ -0,0 +1,112 @@
+package arg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveDependencies finds the options named in the requires, required-if, and
+// required-unless tags of each option in a command tree. An option may refer to
+// the options of its own command and of the commands above it.
+func resolveDependencies(cmd *command, inherited []*spec) error {
+	visible := append(append([]*spec{}, inherited...), cmd.specs...)
+	for _, spec := range cmd.specs {
+		for _, dep := range spec.dependencies {
+			dep.specs = nil
+			for _, name := range dep.names {
+				other := findDependency(visible, strings.TrimSpace(name))
+				if other == nil {
+					return fmt.Errorf("%v: %s refers to unknown option %s", spec.dest, dep.rule, name)
+				}
+				dep.specs = append(dep.specs, other)
+			}
+		}
+	}
+
+	for _, subcmd := range cmd.subcommands {
+		if err := resolveDependencies(subcmd, visible); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findDependency finds an option by its long name or field name
+func findDependency(specs []*spec, name string) *spec {
+	for _, spec := range specs {
+		if spec.long == name || spec.field.Name == name {
+			return spec
+		}
+	}
+	return nil
+}
+
+// checkDependencies checks the requires, required-if, and required-unless
+// relationships for each active spec. Options count as given if they were
+// provided on the command line or by a source other than the defaults.
+func (p *Parser) checkDependencies(active []*spec, wasPresent map[*spec]bool) error {
+	isActive := make(map[*spec]bool)
+	for _, spec := range active {
+		isActive[spec] = true
+	}
+
+	var errs Errors
+	for _, opt := range active {
+		for _, dep := range opt.dependencies {
+			var given, missing []*spec
+			for _, other := range dep.specs {
+				if isActive[other] && wasPresent[other] {
+					given = append(given, other)
+				} else {
+					missing = append(missing, other)
+				}
+			}
+
+			var others []*spec
+			switch {
+			case dep.rule == "requires" && wasPresent[opt] && len(missing) > 0:
+				others = missing
+			case dep.rule == "required-if" && !wasPresent[opt] && len(given) > 0:
+				others = given
+			case dep.rule == "required-unless" && !wasPresent[opt] && len(given) == 0:
+				others = dep.specs
+			default:
+				continue
+			}
+
+			err := &DependencyError{Option: optionOf(opt), Rule: dep.rule, Subcommand: p.subcommandPath()}
+			for _, other := range others {
+				err.Others = append(err.Others, optionOf(other))
+			}
+			if !p.config.CollectErrors {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// withDependencies describes the dependencies of an option for the help text
+func withDependencies(spec *spec) string {
+	var parts []string
+	for _, dep := range spec.dependencies {
+		names := make([]string, len(dep.specs))
+		for i, other := range dep.specs {
+			names[i] = optionName(optionOf(other))
+		}
+		switch dep.rule {
+		case "requires":
+			parts = append(parts, "requires: "+joinList(names, "and"))
+		case "required-if":
+			parts = append(parts, "required if: "+joinList(names, "or"))
+		case "required-unless":
+			parts = append(parts, "required unless: "+joinList(names, "or"))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
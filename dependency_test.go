@@ -0,0 +1,135 @@
+package arg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequires(t *testing.T) {
+	var args struct {
+		TLSKey  string `arg:"--tls-key,requires:tls-cert"`
+		TLSCert string `arg:"--tls-cert"`
+	}
+	err := parse("--tls-key k --tls-cert c", &args)
+	require.NoError(t, err)
+
+	err = parse("--tls-cert c", &args)
+	require.NoError(t, err)
+
+	err = parse("--tls-key k", &args)
+	var e *DependencyError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "tls-key", e.Long)
+	assert.Equal(t, "requires", e.Rule)
+	require.Len(t, e.Others, 1)
+	assert.Equal(t, "tls-cert", e.Others[0].Long)
+	assert.EqualError(t, err, "--tls-key requires --tls-cert")
+}
+
+func TestRequiresSeveral(t *testing.T) {
+	var args struct {
+		A string `arg:"requires:B|C"`
+		B string
+		C string
+	}
+	err := parse("--a x", &args)
+	assert.EqualError(t, err, "--a requires --b and --c")
+
+	err = parse("--a x --c y", &args)
+	assert.EqualError(t, err, "--a requires --b")
+}
+
+func TestRequiredIf(t *testing.T) {
+	var args struct {
+		User     string
+		Password string `arg:"required-if:user,env:REQUIRED_IF_PASSWORD"`
+	}
+	_, err := parseWithEnv(Config{}, "", nil, &args)
+	require.NoError(t, err)
+
+	_, err = parseWithEnv(Config{}, "--user alice", nil, &args)
+	assert.EqualError(t, err, "--password is required when --user is given")
+
+	_, err = parseWithEnv(Config{}, "--user alice", []string{"REQUIRED_IF_PASSWORD=secret"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", args.Password)
+}
+
+func TestRequiredUnless(t *testing.T) {
+	var args struct {
+		Output string `arg:"required-unless:stdout"`
+		Stdout bool
+	}
+	err := parse("--stdout", &args)
+	require.NoError(t, err)
+
+	err = parse("--output x", &args)
+	require.NoError(t, err)
+
+	err = parse("", &args)
+	assert.EqualError(t, err, "--output is required unless --stdout is given")
+}
+
+func TestRequiredUnlessIgnoresDefault(t *testing.T) {
+	var args struct {
+		Output string `arg:"required-unless:stdout"`
+		Stdout bool   `default:"true"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, "--output is required unless --stdout is given")
+}
+
+func TestDependencyOnGlobalOption(t *testing.T) {
+	type pushCmd struct {
+		Force bool `arg:"requires:remote"`
+	}
+	var args struct {
+		Remote string
+		Push   *pushCmd `arg:"subcommand"`
+	}
+	_, err := pparse("push --force", &args)
+	assert.EqualError(t, err, "--force requires --remote")
+
+	_, err = pparse("--remote origin push --force", &args)
+	require.NoError(t, err)
+}
+
+func TestDependencyCollectErrors(t *testing.T) {
+	var args struct {
+		A string `arg:"requires:b"`
+		B string
+		C string `arg:"required-unless:b"`
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "--a x", nil, &args)
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "--a requires --b")
+	assert.EqualError(t, errs[1], "--c is required unless --b is given")
+}
+
+func TestDependencyUnknownOption(t *testing.T) {
+	var args struct {
+		A string `arg:"requires:nope"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, "args.A: requires refers to unknown option nope")
+}
+
+func TestDependencyTagErrors(t *testing.T) {
+	var empty struct {
+		A string `arg:"requires:"`
+	}
+	err := parse("", &empty)
+	assert.EqualError(t, err, ".A: requires must name at least one option")
+
+	var required struct {
+		A string `arg:"required,required-if:b"`
+		B string
+	}
+	err = parse("", &required)
+	assert.EqualError(t, err, ".A: 'required' cannot be used with required-if")
+}
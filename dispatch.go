@@ -0,0 +1,210 @@
+package arg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Runner is the interface that a subcommand struct, or the destination struct of
+// a program without subcommands, implements so that Dispatch can carry out the
+// command that was selected on the command line.
+type Runner interface {
+	// Run carries out the command. The structs for the commands above this one
+	// are available through Parents.
+	Run(ctx context.Context) error
+}
+
+// ExitCoder is the interface that an error returned by Run can implement to
+// choose the status with which Run exits.
+type ExitCoder interface {
+	ExitCode() int
+}
+
+// ExitError is an error that carries the status with which Run should exit
+type ExitError struct {
+	Code int   // the exit status
+	Err  error // the underlying error, or nil to exit without printing a message
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit status
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// BeforeRunner is the interface that the destination struct or a subcommand
+// struct can implement to prepare for running the selected command, for example
+// by setting up logging. BeforeRun is called on each command from the top-level
+// struct down to the selected command, before its Run method.
+type BeforeRunner interface {
+	BeforeRun(ctx context.Context) error
+}
+
+// AfterRunner is the interface that the destination struct or a subcommand
+// struct can implement to clean up after running the selected command. AfterRun
+// is called on each command from the selected command up to the top-level
+// struct, after its Run method has succeeded.
+type AfterRunner interface {
+	AfterRun(ctx context.Context) error
+}
+
+// dispatchKey is the context key under which Dispatch stores the command chain
+type dispatchKey struct{}
+
+// Parents returns the structs for the commands above the one being run by
+// Dispatch, or above the one whose BeforeRun or AfterRun method is being called,
+// starting with the top-level destination structs and followed by each selected
+// subcommand in turn. It returns nil if the context did not come from Dispatch.
+func Parents(ctx context.Context) []interface{} {
+	chain, _ := ctx.Value(dispatchKey{}).([]interface{})
+	if len(chain) == 0 {
+		return nil
+	}
+	return append([]interface{}{}, chain[:len(chain)-1]...)
+}
+
+// commandChain gets the structs for the top-level destinations followed by the
+// struct for each selected subcommand, together with the subcommand path for each
+func (p *Parser) commandChain() ([]interface{}, [][]string) {
+	var chain []interface{}
+	var paths [][]string
+	for _, root := range p.roots {
+		chain = append(chain, root.Interface())
+		paths = append(paths, nil)
+	}
+	cmd := p.cmd
+	for i, name := range p.subcommand {
+		cmd = findSubcommand(cmd.subcommands, name)
+		chain = append(chain, p.val(cmd.dest).Interface())
+		paths = append(paths, append([]string{}, p.subcommand[:i+1]...))
+	}
+	return chain, paths
+}
+
+// Dispatch calls Run on the command selected by the most recent call to Parse,
+// which is the innermost selected subcommand or, if no subcommand was selected,
+// the first top-level destination struct that implements Runner. It returns a
+// CommandNotRunnableError if the selected command does not implement Runner.
+//
+// Before calling Run, Dispatch calls BeforeRun on each command in the chain that
+// implements BeforeRunner, starting with the top-level structs, and after Run
+// succeeds it calls AfterRun on each command that implements AfterRunner in the
+// reverse order. An error from either hook stops the chain and is returned as a
+// HookError.
+func (p *Parser) Dispatch(ctx context.Context) error {
+	chain, paths := p.commandChain()
+
+	var runner Runner
+	if len(p.subcommand) > 0 {
+		runner, _ = chain[len(chain)-1].(Runner)
+	} else {
+		for i, dest := range chain {
+			if r, ok := dest.(Runner); ok {
+				// the runner goes last so that Parents returns the other roots
+				chain = append(append(chain[:i:i], chain[i+1:]...), dest)
+				runner = r
+				break
+			}
+		}
+	}
+
+	if runner == nil {
+		cmd, err := p.lookupCommand(p.subcommand...)
+		if err != nil {
+			return err
+		}
+		var names []string
+		for _, subcmd := range cmd.subcommands {
+			names = append(names, subcmd.name)
+		}
+		return &CommandNotRunnableError{Subcommand: p.subcommandPath(), Subcommands: names}
+	}
+
+	// each hook sees the commands above its own as parents
+	contextFor := func(i int) context.Context {
+		return context.WithValue(ctx, dispatchKey{}, chain[:i+1:i+1])
+	}
+
+	for i, dest := range chain {
+		if hook, ok := dest.(BeforeRunner); ok {
+			if err := hook.BeforeRun(contextFor(i)); err != nil {
+				return &HookError{Hook: "BeforeRun", Subcommand: paths[i], Err: err}
+			}
+		}
+	}
+
+	if err := runner.Run(contextFor(len(chain) - 1)); err != nil {
+		return err
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if hook, ok := chain[i].(AfterRunner); ok {
+			if err := hook.AfterRun(contextFor(i)); err != nil {
+				return &HookError{Hook: "AfterRun", Subcommand: paths[i], Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// Run parses the command line arguments into the given destination structs and
+// then calls Run on the selected command, as described under Dispatch. Problems
+// with the command line are reported in the same way as MustParse, and so are
+// errors from BeforeRun and AfterRun hooks, with the usage information for the
+// command whose hook failed. If the command returns an error then Run prints it
+// and exits with the status given by the first error in its chain that
+// implements ExitCoder, or 1 otherwise. If the command succeeds then Run returns
+// normally.
+func Run(ctx context.Context, dest ...interface{}) {
+	run(ctx, Config{Exit: mustParseExit, Out: mustParseOut, HelpWidth: mustParseHelpWidth}, dest...)
+}
+
+// run is a helper that facilitates testing
+func run(ctx context.Context, config Config, dest ...interface{}) {
+	dests := append(registrations, dest...)
+	p, err := NewParser(config, dests...)
+	if err != nil {
+		fmt.Fprintln(config.Out, err)
+		config.Exit(2)
+		return
+	}
+
+	if err := p.Parse(flags()); err != nil {
+		p.failWithParseError(err)
+		return
+	}
+
+	err = p.Dispatch(ctx)
+	var notRunnable *CommandNotRunnableError
+	var hookErr *HookError
+	switch {
+	case err == nil:
+		return
+	case errors.As(err, &notRunnable):
+		p.FailSubcommand(err.Error(), notRunnable.Subcommand...)
+	case errors.As(err, &hookErr):
+		p.FailSubcommand(err.Error(), hookErr.Subcommand...)
+	default:
+		code := 1
+		var coder ExitCoder
+		if errors.As(err, &coder) {
+			code = coder.ExitCode()
+		}
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Err != nil {
+			fmt.Fprintln(p.config.Out, "error:", err)
+		}
+		p.config.Exit(code)
+	}
+}
@@ -0,0 +1,260 @@
+package arg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dispatchRoot struct {
+	Verbose bool
+	Remote  *dispatchRemote `arg:"subcommand:remote"`
+	Push    *dispatchPush   `arg:"subcommand:push"`
+}
+
+type dispatchRemote struct {
+	Add *dispatchRemoteAdd `arg:"subcommand:add"`
+}
+
+type dispatchRemoteAdd struct {
+	Name    string `arg:"positional"`
+	parents []interface{}
+}
+
+func (c *dispatchRemoteAdd) Run(ctx context.Context) error {
+	c.parents = Parents(ctx)
+	return nil
+}
+
+type dispatchPush struct {
+	Code int
+	ran  bool
+}
+
+func (c *dispatchPush) Run(ctx context.Context) error {
+	c.ran = true
+	if c.Code != 0 {
+		return &ExitError{Code: c.Code, Err: errors.New("push failed")}
+	}
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	var args dispatchRoot
+	p, err := pparse("--verbose remote add origin", &args)
+	require.NoError(t, err)
+	require.NoError(t, p.Dispatch(context.Background()))
+
+	require.NotNil(t, args.Remote)
+	require.NotNil(t, args.Remote.Add)
+	assert.Equal(t, "origin", args.Remote.Add.Name)
+	require.Len(t, args.Remote.Add.parents, 2)
+	assert.Equal(t, &args, args.Remote.Add.parents[0])
+	assert.Equal(t, args.Remote, args.Remote.Add.parents[1])
+}
+
+func TestDispatchReturnsError(t *testing.T) {
+	var args dispatchRoot
+	p, err := pparse("push --code 3", &args)
+	require.NoError(t, err)
+	err = p.Dispatch(context.Background())
+	assert.EqualError(t, err, "push failed")
+	assert.True(t, args.Push.ran)
+}
+
+func TestDispatchNotRunnable(t *testing.T) {
+	var args dispatchRoot
+	p, err := pparse("remote", &args)
+	require.NoError(t, err)
+	err = p.Dispatch(context.Background())
+	var notRunnable *CommandNotRunnableError
+	require.True(t, errors.As(err, &notRunnable))
+	assert.Equal(t, []string{"remote"}, notRunnable.Subcommand)
+	assert.EqualError(t, err, "a subcommand is required (choose from add)")
+
+	p, err = pparse("", &args)
+	require.NoError(t, err)
+	err = p.Dispatch(context.Background())
+	assert.EqualError(t, err, "a subcommand is required (choose from remote, push)")
+}
+
+type dispatchSimple struct {
+	Name string
+	ran  bool
+}
+
+func (a *dispatchSimple) Run(ctx context.Context) error {
+	a.ran = true
+	return nil
+}
+
+func TestDispatchWithoutSubcommands(t *testing.T) {
+	var other struct {
+		Debug bool
+	}
+	var args dispatchSimple
+	p, err := NewParser(Config{}, &other, &args)
+	require.NoError(t, err)
+	require.NoError(t, p.Parse([]string{"--name", "x", "--debug"}))
+	require.NoError(t, p.Dispatch(context.Background()))
+	assert.True(t, args.ran)
+}
+
+func TestParentsOutsideDispatch(t *testing.T) {
+	assert.Nil(t, Parents(context.Background()))
+}
+
+func runWithArgs(t *testing.T, cmdline []string, dest interface{}) (int, string) {
+	originalArgs := os.Args
+	defer func() {
+		os.Args = originalArgs
+	}()
+	os.Args = append([]string{"example"}, cmdline...)
+
+	exitCode := -1
+	var stdout bytes.Buffer
+	exit := func(code int) { exitCode = code }
+	run(context.Background(), Config{Out: &stdout, Exit: exit, HelpWidth: 80}, dest)
+	return exitCode, stdout.String()
+}
+
+func TestRun(t *testing.T) {
+	var args dispatchRoot
+	code, out := runWithArgs(t, []string{"push"}, &args)
+	assert.Equal(t, -1, code)
+	assert.Equal(t, "", out)
+	assert.True(t, args.Push.ran)
+}
+
+func TestRunExitCode(t *testing.T) {
+	var args dispatchRoot
+	code, out := runWithArgs(t, []string{"push", "--code", "3"}, &args)
+	assert.Equal(t, 3, code)
+	assert.Equal(t, "error: push failed\n", out)
+}
+
+func TestRunNotRunnable(t *testing.T) {
+	var args dispatchRoot
+	code, out := runWithArgs(t, []string{"remote"}, &args)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "Usage: example remote <command> [<args>]\nerror: a subcommand is required (choose from add)\n", out)
+}
+
+func TestRunParseError(t *testing.T) {
+	var args dispatchRoot
+	code, out := runWithArgs(t, []string{"push", "--code", "x"}, &args)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, out, "error: error processing --code")
+	assert.False(t, args.Push.ran)
+}
+
+type hookRoot struct {
+	Fail  string
+	Sub   *hookSub `arg:"subcommand:sub"`
+	calls *[]string
+}
+
+func (r *hookRoot) BeforeRun(ctx context.Context) error {
+	*r.calls = append(*r.calls, "before root")
+	if r.Fail == "before-root" {
+		return errors.New("root setup failed")
+	}
+	return nil
+}
+
+func (r *hookRoot) AfterRun(ctx context.Context) error {
+	*r.calls = append(*r.calls, "after root")
+	return nil
+}
+
+type hookSub struct {
+	Leaf    *hookLeaf `arg:"subcommand:leaf"`
+	calls   *[]string
+	parents []interface{}
+}
+
+func (s *hookSub) BeforeRun(ctx context.Context) error {
+	s.parents = Parents(ctx)
+	*s.calls = append(*s.calls, "before sub")
+	return nil
+}
+
+func (s *hookSub) AfterRun(ctx context.Context) error {
+	*s.calls = append(*s.calls, "after sub")
+	if root := Parents(ctx)[0].(*hookRoot); root.Fail == "after-sub" {
+		return errors.New("sub cleanup failed")
+	}
+	return nil
+}
+
+type hookLeaf struct {
+	calls *[]string
+	fail  bool
+}
+
+func (l *hookLeaf) Run(ctx context.Context) error {
+	*l.calls = append(*l.calls, "run leaf")
+	if l.fail {
+		return errors.New("leaf failed")
+	}
+	return nil
+}
+
+func newHookRoot(calls *[]string) *hookRoot {
+	return &hookRoot{
+		calls: calls,
+		Sub: &hookSub{
+			calls: calls,
+			Leaf:  &hookLeaf{calls: calls},
+		},
+	}
+}
+
+func TestDispatchHooks(t *testing.T) {
+	var calls []string
+	args := newHookRoot(&calls)
+	p, err := pparse("sub leaf", args)
+	require.NoError(t, err)
+	require.NoError(t, p.Dispatch(context.Background()))
+	assert.Equal(t, []string{"before root", "before sub", "run leaf", "after sub", "after root"}, calls)
+	assert.Equal(t, []interface{}{args}, args.Sub.parents)
+}
+
+func TestDispatchBeforeHookError(t *testing.T) {
+	var calls []string
+	args := newHookRoot(&calls)
+	p, err := pparse("--fail before-root sub leaf", args)
+	require.NoError(t, err)
+	err = p.Dispatch(context.Background())
+	assert.EqualError(t, err, "root setup failed")
+	var hookErr *HookError
+	require.True(t, errors.As(err, &hookErr))
+	assert.Equal(t, "BeforeRun", hookErr.Hook)
+	assert.Empty(t, hookErr.Subcommand)
+	assert.Equal(t, []string{"before root"}, calls)
+}
+
+func TestDispatchAfterHooksSkippedOnError(t *testing.T) {
+	var calls []string
+	args := newHookRoot(&calls)
+	args.Sub.Leaf.fail = true
+	p, err := pparse("sub leaf", args)
+	require.NoError(t, err)
+	err = p.Dispatch(context.Background())
+	assert.EqualError(t, err, "leaf failed")
+	assert.Equal(t, []string{"before root", "before sub", "run leaf"}, calls)
+}
+
+func TestRunAfterHookError(t *testing.T) {
+	var calls []string
+	args := newHookRoot(&calls)
+	code, out := runWithArgs(t, []string{"--fail", "after-sub", "sub", "leaf"}, args)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "Usage: example sub <command> [<args>]\nerror: sub cleanup failed\n", out)
+	assert.Equal(t, []string{"before root", "before sub", "run leaf", "after sub"}, calls)
+}
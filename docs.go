@@ -0,0 +1,445 @@
+package arg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// DocFormat is the format of the reference documentation written by WriteDoc
+type DocFormat int
+
+const (
+	MarkdownFormat DocFormat = iota // Markdown, with tables and inline HTML anchors
+	HTMLFormat                      // a standalone HTML page
+)
+
+// extension gets the file extension for pages in a format
+func (f DocFormat) extension() string {
+	if f == HTMLFormat {
+		return ".html"
+	}
+	return ".md"
+}
+
+// docPage is the content of the reference page for one command
+type docPage struct {
+	title       string // the program name followed by the subcommand names
+	anchor      string
+	description string
+	usage       string
+	positionals []docRow
+	options     []docRow
+	globals     []docRow
+	subcommands []docLink
+	epilogue    string
+}
+
+// docRow describes one option or positional argument
+type docRow struct {
+	anchor   string // the anchor for this row, or empty for rows that link elsewhere
+	link     string // for global options, the link to the page that describes the option
+	name     string // for positionals, the placeholder
+	long     string
+	short    string
+	typ      string
+	def      string
+	env      string
+	required bool
+	help     string
+}
+
+// docLink describes a subcommand
+type docLink struct {
+	name string
+	link string
+	help string
+}
+
+// docAnchor gets the anchor for a command, which depends only on the names of the
+// program and subcommands so that links remain valid between releases
+func docAnchor(program string, subcommand []string) string {
+	return anchorText(manPageName(program, subcommand))
+}
+
+// specAnchor gets the anchor for an option or positional argument, which depends
+// only on the command it belongs to and its long name, short name, or environment
+// variable
+func specAnchor(program string, spec *spec) string {
+	prefix := docAnchor(program, spec.commandPath)
+	switch {
+	case spec.positional:
+		return prefix + "-arg-" + anchorText(spec.long)
+	case spec.long != "":
+		return prefix + "-option-" + anchorText(spec.long)
+	case spec.short != "":
+		return prefix + "-option-" + anchorText(spec.short)
+	default:
+		return prefix + "-env-" + anchorText(spec.env)
+	}
+}
+
+// anchorText lowercases a string and replaces each run of characters other than
+// letters and digits with a single hyphen
+func anchorText(s string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
+// docTypeName describes the type of a field in Go syntax without package names
+func docTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return docTypeName(t.Elem())
+	case reflect.Slice:
+		return "[]" + docTypeName(t.Elem())
+	case reflect.Map:
+		return "map[" + docTypeName(t.Key()) + "]" + docTypeName(t.Elem())
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
+}
+
+// docPageFor collects the content of the reference page for a command
+func (p *Parser) docPageFor(cmd *command, subcommand []string, format DocFormat) *docPage {
+	page := &docPage{
+		title:       strings.Join(append([]string{p.cmd.name}, subcommand...), " "),
+		anchor:      docAnchor(p.cmd.name, subcommand),
+		description: cmd.help,
+	}
+	if cmd.parent == nil {
+		page.description = p.description
+		page.epilogue = p.epilogue
+	}
+
+	// the usage is wrapped to the default width rather than the terminal width so
+	// that the pages do not depend on where they were generated
+	var usage bytes.Buffer
+	p.writeUsage(&usage, defaultHelpWidth, subcommand...)
+	page.usage = strings.TrimSpace(usage.String())
+
+	row := func(spec *spec) docRow {
+		r := docRow{
+			anchor:   specAnchor(p.cmd.name, spec),
+			long:     spec.long,
+			short:    spec.short,
+			def:      spec.defaultString,
+			env:      spec.env,
+			required: spec.required,
+			help:     spec.help,
+		}
+		if spec.field.Type != nil {
+			r.typ = docTypeName(spec.field.Type)
+		}
+		if spec.positional {
+			r.name, r.long = spec.placeholder, ""
+		}
+		return r
+	}
+
+	var hasVersionOption bool
+	for _, spec := range cmd.specs {
+		if spec.long == "version" {
+			hasVersionOption = true
+		}
+		if spec.hidden {
+			continue
+		}
+		if spec.positional {
+			page.positionals = append(page.positionals, row(spec))
+		} else {
+			page.options = append(page.options, row(spec))
+		}
+	}
+
+	for ancestor := cmd.parent; ancestor != nil; ancestor = ancestor.parent {
+		for _, spec := range ancestor.specs {
+			if spec.long == "version" {
+				hasVersionOption = true
+			}
+			if spec.hidden || spec.positional {
+				continue
+			}
+			r := row(spec)
+			r.link = manPageName(p.cmd.name, spec.commandPath) + format.extension() + "#" + r.anchor
+			r.anchor = ""
+			page.globals = append(page.globals, r)
+		}
+	}
+
+	// the built in options go at the end of the options
+	page.options = append(page.options, docRow{
+		anchor: page.anchor + "-option-help",
+		long:   "help",
+		short:  "h",
+		help:   "display this help and exit",
+	})
+	if !hasVersionOption && p.version != "" {
+		page.options = append(page.options, docRow{
+			anchor: page.anchor + "-option-version",
+			long:   "version",
+			help:   "display version and exit",
+		})
+	}
+
+	for _, subcmd := range cmd.subcommands {
+		if subcmd.hidden {
+			continue
+		}
+		path := append(append([]string{}, subcommand...), subcmd.name)
+		page.subcommands = append(page.subcommands, docLink{
+			name: subcmd.name,
+			link: manPageName(p.cmd.name, path) + format.extension() + "#" + docAnchor(p.cmd.name, path),
+			help: subcmd.help,
+		})
+	}
+	return page
+}
+
+// WriteDoc writes the reference documentation for the top-level command or for a
+// subcommand, which is given as a sequence of subcommand names in the same way as
+// for WriteHelpForSubcommand. The page contains the usage, tables of positional
+// arguments, options, and global options inherited from parent commands, and links
+// to the pages for subcommands, which are named in the same way as by WriteDocs.
+// Each command, option, and positional argument has an anchor that depends only on
+// its name, such as example-remote-option-fetch for the --fetch option of the
+// remote subcommand, so that links remain valid between releases.
+func (p *Parser) WriteDoc(w io.Writer, format DocFormat, subcommand ...string) error {
+	cmd, err := p.lookupCommand(subcommand...)
+	if err != nil {
+		return err
+	}
+
+	page := p.docPageFor(cmd, subcommand, format)
+	switch format {
+	case MarkdownFormat:
+		writeMarkdownDoc(w, page)
+	case HTMLFormat:
+		writeHTMLDoc(w, page)
+	default:
+		return fmt.Errorf("unknown documentation format %d", int(format))
+	}
+	return nil
+}
+
+// WriteDocs writes the reference documentation for the top-level command and for
+// each visible subcommand into a directory, with file names such as example.md
+// and example-remote.md, or example.html and example-remote.html
+func (p *Parser) WriteDocs(dir string, format DocFormat) error {
+	var write func(cmd *command, path []string) error
+	write = func(cmd *command, path []string) error {
+		var buf bytes.Buffer
+		if err := p.WriteDoc(&buf, format, path...); err != nil {
+			return err
+		}
+		filename := filepath.Join(dir, manPageName(p.cmd.name, path)+format.extension())
+		if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		for _, subcmd := range cmd.subcommands {
+			if subcmd.hidden {
+				continue
+			}
+			if err := write(subcmd, append(append([]string{}, path...), subcmd.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return write(p.cmd, nil)
+}
+
+// markdownCell escapes text for a cell in a Markdown table
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
+
+// markdownCode formats text as inline code, or returns the empty string for empty text
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func writeMarkdownDoc(w io.Writer, page *docPage) {
+	fmt.Fprintf(w, "<a id=\"%s\"></a>\n\n", page.anchor)
+	fmt.Fprintf(w, "# %s\n\n", page.title)
+	if page.description != "" {
+		fmt.Fprintf(w, "%s\n\n", page.description)
+	}
+	fmt.Fprintf(w, "```\n%s\n```\n", page.usage)
+
+	if len(page.positionals) > 0 {
+		fmt.Fprintf(w, "\n## Arguments\n\n")
+		fmt.Fprintf(w, "| Name | Type | Default | Environment | Required | Description |\n")
+		fmt.Fprintf(w, "| --- | --- | --- | --- | --- | --- |\n")
+		for _, r := range page.positionals {
+			fmt.Fprintf(w, "| <a id=\"%s\"></a>%s | %s | %s | %s | %s | %s |\n",
+				r.anchor, markdownCode(r.name), markdownCode(r.typ), markdownCode(r.def),
+				markdownCode(r.env), yesNo(r.required), markdownCell(r.help))
+		}
+	}
+
+	writeOptions := func(title string, rows []docRow) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n## %s\n\n", title)
+		fmt.Fprintf(w, "| Long | Short | Type | Default | Environment | Required | Description |\n")
+		fmt.Fprintf(w, "| --- | --- | --- | --- | --- | --- | --- |\n")
+		for _, r := range rows {
+			long := ""
+			if r.long != "" {
+				long = markdownCode("--" + r.long)
+			}
+			short := ""
+			if r.short != "" {
+				short = markdownCode("-" + r.short)
+			}
+			env := markdownCode(r.env)
+			if r.link != "" {
+				// link from the first form of the option that there is
+				for _, cell := range []*string{&long, &short, &env} {
+					if *cell != "" {
+						*cell = fmt.Sprintf("[%s](%s)", *cell, r.link)
+						break
+					}
+				}
+			} else {
+				long = fmt.Sprintf("<a id=\"%s\"></a>%s", r.anchor, long)
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n",
+				long, short, markdownCode(r.typ), markdownCode(r.def),
+				env, yesNo(r.required), markdownCell(r.help))
+		}
+	}
+	writeOptions("Options", page.options)
+	writeOptions("Global options", page.globals)
+
+	if len(page.subcommands) > 0 {
+		fmt.Fprintf(w, "\n## Commands\n\n")
+		fmt.Fprintf(w, "| Command | Description |\n")
+		fmt.Fprintf(w, "| --- | --- |\n")
+		for _, link := range page.subcommands {
+			fmt.Fprintf(w, "| [%s](%s) | %s |\n", link.name, link.link, markdownCell(link.help))
+		}
+	}
+
+	if page.epilogue != "" {
+		fmt.Fprintf(w, "\n%s\n", page.epilogue)
+	}
+}
+
+// htmlText escapes text for HTML, keeping line breaks
+func htmlText(s string) string {
+	return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+}
+
+// htmlCode formats text as inline code, or returns the empty string for empty text
+func htmlCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "<code>" + html.EscapeString(s) + "</code>"
+}
+
+func writeHTMLDoc(w io.Writer, page *docPage) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(w, "<title>%s</title>\n</head>\n<body>\n", html.EscapeString(page.title))
+	fmt.Fprintf(w, "<h1 id=\"%s\">%s</h1>\n", page.anchor, html.EscapeString(page.title))
+	if page.description != "" {
+		fmt.Fprintf(w, "<p>%s</p>\n", htmlText(page.description))
+	}
+	fmt.Fprintf(w, "<pre>%s</pre>\n", html.EscapeString(page.usage))
+
+	if len(page.positionals) > 0 {
+		fmt.Fprintf(w, "<h2>Arguments</h2>\n<table>\n")
+		fmt.Fprintf(w, "<tr><th>Name</th><th>Type</th><th>Default</th><th>Environment</th><th>Required</th><th>Description</th></tr>\n")
+		for _, r := range page.positionals {
+			fmt.Fprintf(w, "<tr id=\"%s\"><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				r.anchor, htmlCode(r.name), htmlCode(r.typ), htmlCode(r.def),
+				htmlCode(r.env), yesNo(r.required), htmlText(r.help))
+		}
+		fmt.Fprintf(w, "</table>\n")
+	}
+
+	writeOptions := func(title string, rows []docRow) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "<h2>%s</h2>\n<table>\n", title)
+		fmt.Fprintf(w, "<tr><th>Long</th><th>Short</th><th>Type</th><th>Default</th><th>Environment</th><th>Required</th><th>Description</th></tr>\n")
+		for _, r := range rows {
+			long := ""
+			if r.long != "" {
+				long = htmlCode("--" + r.long)
+			}
+			short := ""
+			if r.short != "" {
+				short = htmlCode("-" + r.short)
+			}
+			env := htmlCode(r.env)
+			id := ""
+			if r.link != "" {
+				// link from the first form of the option that there is
+				for _, cell := range []*string{&long, &short, &env} {
+					if *cell != "" {
+						*cell = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(r.link), *cell)
+						break
+					}
+				}
+			} else {
+				id = fmt.Sprintf(" id=\"%s\"", r.anchor)
+			}
+			fmt.Fprintf(w, "<tr%s><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				id, long, short, htmlCode(r.typ), htmlCode(r.def),
+				env, yesNo(r.required), htmlText(r.help))
+		}
+		fmt.Fprintf(w, "</table>\n")
+	}
+	writeOptions("Options", page.options)
+	writeOptions("Global options", page.globals)
+
+	if len(page.subcommands) > 0 {
+		fmt.Fprintf(w, "<h2>Commands</h2>\n<table>\n")
+		fmt.Fprintf(w, "<tr><th>Command</th><th>Description</th></tr>\n")
+		for _, link := range page.subcommands {
+			fmt.Fprintf(w, "<tr><td><a href=\"%s\">%s</a></td><td>%s</td></tr>\n",
+				html.EscapeString(link.link), html.EscapeString(link.name), htmlText(link.help))
+		}
+		fmt.Fprintf(w, "</table>\n")
+	}
+
+	if page.epilogue != "" {
+		fmt.Fprintf(w, "<p>%s</p>\n", htmlText(page.epilogue))
+	}
+	fmt.Fprintf(w, "</body>\n</html>\n")
+}
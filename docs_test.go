@@ -0,0 +1,136 @@
+package arg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDocMarkdown(t *testing.T) {
+	expected := "<a id=\"example\"></a>\n" +
+		"\n" +
+		"# example\n" +
+		"\n" +
+		"example manages things\n" +
+		"in several ways\n" +
+		"\n" +
+		"```\n" +
+		"Usage: example [--verbose] [--level LEVEL] [--output OUTPUT] <command> [<args>]\n" +
+		"```\n" +
+		"\n" +
+		"## Options\n" +
+		"\n" +
+		"| Long | Short | Type | Default | Environment | Required | Description |\n" +
+		"| --- | --- | --- | --- | --- | --- | --- |\n" +
+		"| <a id=\"example-option-verbose\"></a>`--verbose` | `-v` | `bool` |  |  | no | be verbose |\n" +
+		"| <a id=\"example-option-level\"></a>`--level` |  | `string` | `info` |  | no | log level |\n" +
+		"| <a id=\"example-option-output\"></a>`--output` |  | `string` |  | `OUTPUT` | no | where to write |\n" +
+		"| <a id=\"example-env-api-token\"></a> |  | `string` |  | `API_TOKEN` | no | the API token |\n" +
+		"| <a id=\"example-option-help\"></a>`--help` | `-h` |  |  |  | no | display this help and exit |\n" +
+		"| <a id=\"example-option-version\"></a>`--version` |  |  |  |  | no | display version and exit |\n" +
+		"\n" +
+		"## Commands\n" +
+		"\n" +
+		"| Command | Description |\n" +
+		"| --- | --- |\n" +
+		"| [remote](example-remote.md#example-remote) | manage remotes |\n" +
+		"\n" +
+		"For more information visit example.com\n"
+
+	var args manArgs
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteDoc(&b, MarkdownFormat))
+	assert.Equal(t, expected, b.String())
+}
+
+func TestWriteDocMarkdownForSubcommand(t *testing.T) {
+	var args manArgs
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteDoc(&b, MarkdownFormat, "remote", "add"))
+	page := b.String()
+	assert.Contains(t, page, "# example remote add\n\nadd a remote\n")
+	assert.Contains(t, page, "| <a id=\"example-remote-add-arg-name\"></a>`NAME` | `string` |  |  | yes | name of the remote |\n")
+	assert.Contains(t, page, "| <a id=\"example-remote-add-option-fetch\"></a>`--fetch` | `-f` | `bool` |  |  | no | fetch after adding |\n")
+	assert.Contains(t, page, "## Global options\n")
+	assert.Contains(t, page, "| [`--verbose`](example.md#example-option-verbose) | `-v` |")
+	assert.Contains(t, page, "|  |  | `string` |  | [`API_TOKEN`](example.md#example-env-api-token) |")
+	assert.NotContains(t, page, "## Commands")
+	assert.NotContains(t, page, "example.com")
+
+	err = p.WriteDoc(&b, MarkdownFormat, "nope")
+	assert.Error(t, err)
+}
+
+func TestWriteDocHTML(t *testing.T) {
+	var args struct {
+		Count map[string]int `help:"counts <by> key"`
+		Sub   *struct {
+			Files []*string `arg:"positional" help:"files | paths"`
+		} `arg:"subcommand:sub"`
+	}
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteDoc(&b, HTMLFormat))
+	page := b.String()
+	assert.Contains(t, page, "<title>example</title>\n")
+	assert.Contains(t, page, "<h1 id=\"example\">example</h1>\n")
+	assert.Contains(t, page, "<tr id=\"example-option-count\"><td><code>--count</code></td><td></td><td><code>map[string]int</code></td><td></td><td></td><td>no</td><td>counts &lt;by&gt; key</td></tr>\n")
+	assert.Contains(t, page, "<tr><td><a href=\"example-sub.html#example-sub\">sub</a></td><td></td></tr>\n")
+
+	b.Reset()
+	require.NoError(t, p.WriteDoc(&b, HTMLFormat, "sub"))
+	page = b.String()
+	assert.Contains(t, page, "<tr id=\"example-sub-arg-files\"><td><code>FILES</code></td><td><code>[]string</code></td>")
+	assert.Contains(t, page, "<td><a href=\"example.html#example-option-count\"><code>--count</code></a></td>")
+}
+
+func TestWriteDocMarkdownEscaping(t *testing.T) {
+	var args struct {
+		Mode string `help:"either a|b\nor c"`
+	}
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteDoc(&b, MarkdownFormat))
+	assert.Contains(t, b.String(), "| either a\\|b<br>or c |\n")
+}
+
+func TestAnchorText(t *testing.T) {
+	assert.Equal(t, "example-remote-add", anchorText("example-remote-add"))
+	assert.Equal(t, "my-prog-dry-run", anchorText("My_Prog--dry.run"))
+	assert.Equal(t, "api-token", anchorText("_API_TOKEN_"))
+}
+
+func TestWriteDocs(t *testing.T) {
+	var args manArgs
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, p.WriteDocs(dir, MarkdownFormat))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"example-remote-add.md", "example-remote.md", "example.md"}, names)
+
+	buf, err := os.ReadFile(filepath.Join(dir, "example-remote.md"))
+	require.NoError(t, err)
+	assert.Contains(t, string(buf), "| [add](example-remote-add.md#example-remote-add) | add a remote |\n")
+}
@@ -0,0 +1,321 @@
+package arg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Option describes a command line option, positional argument, or environment
+// variable in a form that is independent of the struct field it was created from.
+type Option struct {
+	Long        string   // the long name without hyphens, or empty if none
+	Short       string   // the short name without a hyphen, or empty if none
+	Env         string   // the environment variable, or empty if none
+	Placeholder string   // the placeholder used in help text, such as FILE
+	Positional  bool     // true if this is a positional argument
+	Multiple    bool     // true if this option takes more than one value
+	Command     []string // the names of the subcommands under which this option is defined, or empty for top-level options
+}
+
+// optionOf gets the exported description of a spec
+func optionOf(spec *spec) Option {
+	opt := Option{
+		Env:         spec.env,
+		Placeholder: spec.placeholder,
+		Positional:  spec.positional,
+		Multiple:    spec.cardinality == multiple,
+		Command:     spec.commandPath,
+	}
+	if !spec.positional {
+		opt.Long = spec.long
+		opt.Short = spec.short
+	}
+	return opt
+}
+
+// UnknownArgumentError is returned when a command line argument does not match
+// any option that is active for the current subcommand.
+type UnknownArgumentError struct {
+	Arg        string   // the argument as it appeared on the command line
+	Subcommand []string // the subcommands that had been selected when the argument was encountered
+}
+
+func (e *UnknownArgumentError) Error() string {
+	return fmt.Sprintf("unknown argument %s", e.Arg)
+}
+
+// MissingValueError is returned when an option that requires a value is the last
+// command line argument or is followed by something that is not a value, or when
+// an option is given fewer values than it needs.
+type MissingValueError struct {
+	Option
+	Arg        string   // how the option was provided, such as "--point" or "environment variable POINT", or empty for a config file or Source
+	MinValues  int      // for options that were given too few values, the fewest they need, otherwise 0
+	MaxValues  int      // for options that were given too few values, the most they take, otherwise 0
+	Got        int      // the number of values that were given
+	Subcommand []string // the subcommands that had been selected when the argument was encountered
+}
+
+func (e *MissingValueError) Error() string {
+	if e.MaxValues == 0 {
+		return fmt.Sprintf("missing value for %s", e.Arg)
+	}
+	return valueCountMessage(e.Option, e.Arg, e.MinValues, e.MaxValues, e.Got)
+}
+
+// TooManyValuesError is returned when an environment variable, a config file, or
+// a Source gives an option more values than it takes.
+type TooManyValuesError struct {
+	Option
+	Arg        string   // how the option was provided, such as "environment variable POINT", or empty for a config file or Source
+	MinValues  int      // the fewest values the option needs
+	MaxValues  int      // the most values the option takes
+	Got        int      // the number of values that were given
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *TooManyValuesError) Error() string {
+	return valueCountMessage(e.Option, e.Arg, e.MinValues, e.MaxValues, e.Got)
+}
+
+// valueCountMessage describes an option that was given the wrong number of values
+func valueCountMessage(opt Option, arg string, min, max, got int) string {
+	msg := fmt.Sprintf("requires %s but got %d", arity(min, max), got)
+	if !opt.Multiple {
+		msg = fmt.Sprintf("expected a single value but got %d", got)
+	}
+	if arg != "" {
+		msg = arg + " " + msg
+	}
+	return msg
+}
+
+// TooManyPositionalsError is returned when there are more positional arguments on
+// the command line than there are positional fields to store them in.
+type TooManyPositionalsError struct {
+	Arg        string   // the first positional argument that could not be stored
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *TooManyPositionalsError) Error() string {
+	return fmt.Sprintf("too many positional arguments at '%s'", e.Arg)
+}
+
+// RequiredArgumentError is returned when a required option or positional was not
+// provided on the command line or through its environment variable.
+type RequiredArgumentError struct {
+	Option
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *RequiredArgumentError) Error() string {
+	if !e.Positional && e.Long == "" && e.Short == "" {
+		return fmt.Sprintf("environment variable %s is required", e.Env)
+	}
+
+	msg := fmt.Sprintf("%s is required", e.Placeholder)
+	if e.Env != "" {
+		msg += " (or environment variable " + e.Env + ")"
+	}
+	return msg
+}
+
+// InvalidSubcommandError is returned when a subcommand name is not recognized.
+type InvalidSubcommandError struct {
+	Name       string   // the subcommand name as it appeared on the command line
+	Subcommand []string // the subcommands that had been selected before this one
+}
+
+func (e *InvalidSubcommandError) Error() string {
+	return fmt.Sprintf("invalid subcommand: %s", e.Name)
+}
+
+// ValueParseError is returned when a value from the command line, from an
+// environment variable, or from a Source cannot be parsed into the type of its
+// field. The underlying error is available through errors.Unwrap.
+type ValueParseError struct {
+	Option
+	Origin     OriginKind // where the value came from: OriginCommandLine, OriginEnv, or OriginSource
+	Arg        string     // for OriginCommandLine, the option as it appeared on the command line, or empty for positionals
+	Value      string     // the text that could not be parsed, or all the values separated by spaces for options that take several
+	Subcommand []string   // the subcommands that had been selected when the value was encountered
+	Source     Source     // for OriginSource, the source from Config.Sources that supplied the value
+	Err        error      // the error from parsing the value
+}
+
+func (e *ValueParseError) Error() string {
+	switch {
+	case e.Origin == OriginEnv && e.Multiple:
+		return fmt.Sprintf("error processing environment variable %s with multiple values: %v", e.Env, e.Err)
+	case e.Origin == OriginEnv:
+		return fmt.Sprintf("error processing environment variable %s: %v", e.Env, e.Err)
+	case e.Origin == OriginSource:
+		return fmt.Sprintf("error processing value for %s: %v", optionName(e.Option), e.Err)
+	case e.Arg != "":
+		return fmt.Sprintf("error processing %s: %v", e.Arg, e.Err)
+	default:
+		return fmt.Sprintf("error processing %s: %v", optionName(e.Option), e.Err)
+	}
+}
+
+func (e *ValueParseError) Unwrap() error {
+	return e.Err
+}
+
+// GroupConflictError is returned when more than one option from an exclusive group
+// is provided.
+type GroupConflictError struct {
+	Group      string   // the name of the group
+	Options    []Option // the options from the group that were provided
+	Args       []string // how each option was provided, such as "--json" or "environment variable FORMAT"
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *GroupConflictError) Error() string {
+	return fmt.Sprintf("%s cannot be used together", joinList(e.Args, "and"))
+}
+
+// GroupRequiredError is returned when none of the options from a one-required
+// group is provided.
+type GroupRequiredError struct {
+	Group      string   // the name of the group
+	Options    []Option // the options in the group
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *GroupRequiredError) Error() string {
+	names := make([]string, len(e.Options))
+	for i, opt := range e.Options {
+		names[i] = optionName(opt)
+	}
+	return fmt.Sprintf("one of %s is required", joinList(names, "or"))
+}
+
+// DependencyError is returned when a requires, required-if, or required-unless
+// relationship between options is not satisfied.
+type DependencyError struct {
+	Option
+	Rule       string   // the tag that created the relationship: requires, required-if, or required-unless
+	Others     []Option // for requires, the options that are missing, for required-if, the options that were given, and for required-unless, all the options named by the tag
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *DependencyError) Error() string {
+	names := make([]string, len(e.Others))
+	for i, opt := range e.Others {
+		names[i] = optionName(opt)
+	}
+	switch e.Rule {
+	case "requires":
+		return fmt.Sprintf("%s requires %s", optionName(e.Option), joinList(names, "and"))
+	case "required-if":
+		return fmt.Sprintf("%s is required when %s is given", optionName(e.Option), joinList(names, "or"))
+	default:
+		return fmt.Sprintf("%s is required unless %s is given", optionName(e.Option), joinList(names, "or"))
+	}
+}
+
+// ValidationError is returned when the value of an option does not satisfy a
+// rule from its validate tag.
+type ValidationError struct {
+	Option
+	Arg        string   // how the option was provided, such as "--port" or "environment variable PORT"
+	Rule       string   // the name of the rule, such as "min" or "regex"
+	Value      string   // the value that did not satisfy the rule
+	Problem    string   // a description of the problem, such as "must be at least 1"
+	Subcommand []string // the subcommands that were selected
+}
+
+func (e *ValidationError) Error() string {
+	if e.Rule == "nonempty" {
+		return fmt.Sprintf("%s %s", e.Arg, e.Problem)
+	}
+	return fmt.Sprintf("%s %s (got %s)", e.Arg, e.Problem, e.Value)
+}
+
+// ValidatorError is returned when the Validate method of a destination struct, an
+// embedded struct, or a subcommand struct returns an error. The error returned by
+// Validate is available through errors.Unwrap.
+type ValidatorError struct {
+	Subcommand []string // the subcommand whose struct returned the error, or empty for the top-level command
+	Err        error    // the error returned by Validate
+}
+
+func (e *ValidatorError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidatorError) Unwrap() error {
+	return e.Err
+}
+
+// CommandNotRunnableError is returned by Dispatch when the selected command does
+// not implement Runner, which usually means that one of its subcommands should
+// have been given
+type CommandNotRunnableError struct {
+	Subcommand  []string // the selected subcommand, or empty for the top-level command
+	Subcommands []string // the names of the subcommands of the selected command
+}
+
+func (e *CommandNotRunnableError) Error() string {
+	switch {
+	case len(e.Subcommands) > 0:
+		return fmt.Sprintf("a subcommand is required (choose from %s)", strings.Join(e.Subcommands, ", "))
+	case len(e.Subcommand) > 0:
+		return fmt.Sprintf("the %s command cannot be run", strings.Join(e.Subcommand, " "))
+	default:
+		return "there is no command to run"
+	}
+}
+
+// HookError is returned by Dispatch when a BeforeRun or AfterRun method returns an
+// error. The error returned by the hook is available through errors.Unwrap.
+type HookError struct {
+	Hook       string   // either "BeforeRun" or "AfterRun"
+	Subcommand []string // the subcommand whose hook returned the error, or empty for the top-level command
+	Err        error    // the error returned by the hook
+}
+
+func (e *HookError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigFileError is returned when a config file cannot be parsed or contains a
+// value that cannot be stored in its field.
+type ConfigFileError struct {
+	File string // the path to the config file
+	Key  string // the key containing the value, with subcommand sections separated by periods, or empty if the file could not be parsed
+	Err  error  // the underlying error
+}
+
+func (e *ConfigFileError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("error reading config file %s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s: error processing %s: %v", e.File, e.Key, e.Err)
+}
+
+func (e *ConfigFileError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is a list of problems with the command line. It is returned by Parse
+// when Config.CollectErrors is set, and the individual errors are available
+// through errors.As and errors.Is.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,293 @@
+package arg
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownArgumentError(t *testing.T) {
+	type getCmd struct{}
+	var args struct {
+		Get *getCmd `arg:"subcommand"`
+	}
+	err := parse("get --foo", &args)
+
+	var e *UnknownArgumentError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "--foo", e.Arg)
+	assert.Equal(t, []string{"get"}, e.Subcommand)
+	assert.EqualError(t, err, "unknown argument --foo")
+}
+
+func TestMissingValueError(t *testing.T) {
+	var args struct {
+		Foo string `arg:"-f,env:MISSING_VALUE_FOO"`
+	}
+	err := parse("-f", &args)
+
+	var e *MissingValueError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "-f", e.Arg)
+	assert.Equal(t, "foo", e.Long)
+	assert.Equal(t, "f", e.Short)
+	assert.Equal(t, "MISSING_VALUE_FOO", e.Env)
+	assert.Empty(t, e.Subcommand)
+	assert.EqualError(t, err, "missing value for -f")
+}
+
+func TestMissingValueErrorForTooFewValues(t *testing.T) {
+	var args struct {
+		Sub *struct {
+			Point []int `nargs:"2"`
+		} `arg:"subcommand:sub"`
+	}
+	err := parse("sub --point 1", &args)
+
+	var e *MissingValueError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "--point", e.Arg)
+	assert.Equal(t, "point", e.Long)
+	assert.Equal(t, 2, e.MinValues)
+	assert.Equal(t, 2, e.MaxValues)
+	assert.Equal(t, 1, e.Got)
+	assert.Equal(t, []string{"sub"}, e.Subcommand)
+	assert.EqualError(t, err, "--point requires 2 values but got 1")
+}
+
+func TestTooManyValuesError(t *testing.T) {
+	var args struct {
+		Point []int `nargs:"2" arg:"env:TOO_MANY_POINT"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"TOO_MANY_POINT=1,2,3"}, &args)
+
+	var e *TooManyValuesError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "environment variable TOO_MANY_POINT", e.Arg)
+	assert.Equal(t, "TOO_MANY_POINT", e.Env)
+	assert.Equal(t, 3, e.Got)
+	assert.EqualError(t, err, "environment variable TOO_MANY_POINT requires 2 values but got 3")
+}
+
+func TestTooManyPositionalsError(t *testing.T) {
+	var args struct {
+		Sub *struct {
+			Input string `arg:"positional"`
+		} `arg:"subcommand:sub"`
+	}
+	err := parse("sub a b", &args)
+
+	var e *TooManyPositionalsError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "b", e.Arg)
+	assert.Equal(t, []string{"sub"}, e.Subcommand)
+	assert.EqualError(t, err, "too many positional arguments at 'b'")
+}
+
+func TestRequiredArgumentError(t *testing.T) {
+	var args struct {
+		Foo string `arg:"required,env:REQUIRED_FOO"`
+	}
+	err := parse("", &args)
+
+	var e *RequiredArgumentError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "foo", e.Long)
+	assert.Equal(t, "REQUIRED_FOO", e.Env)
+	assert.False(t, e.Positional)
+	assert.EqualError(t, err, "FOO is required (or environment variable REQUIRED_FOO)")
+}
+
+func TestRequiredArgumentErrorForPositional(t *testing.T) {
+	var args struct {
+		Input string `arg:"positional,required"`
+	}
+	err := parse("", &args)
+
+	var e *RequiredArgumentError
+	require.True(t, errors.As(err, &e))
+	assert.True(t, e.Positional)
+	assert.Empty(t, e.Long)
+	assert.Equal(t, "INPUT", e.Placeholder)
+	assert.EqualError(t, err, "INPUT is required")
+}
+
+func TestRequiredArgumentErrorForEnvOnly(t *testing.T) {
+	var args struct {
+		Key string `arg:"required,--,env:REQUIRED_KEY"`
+	}
+	err := parse("", &args)
+
+	var e *RequiredArgumentError
+	require.True(t, errors.As(err, &e))
+	assert.EqualError(t, err, "environment variable REQUIRED_KEY is required")
+}
+
+func TestInvalidSubcommandError(t *testing.T) {
+	type getCmd struct{}
+	var args struct {
+		Get *getCmd `arg:"subcommand"`
+	}
+	err := parse("put", &args)
+
+	var e *InvalidSubcommandError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "put", e.Name)
+	assert.Empty(t, e.Subcommand)
+	assert.EqualError(t, err, "invalid subcommand: put")
+}
+
+func TestValueParseError(t *testing.T) {
+	var args struct {
+		Count int `arg:"-c"`
+	}
+	err := parse("--count=abc", &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "--count=abc", e.Arg)
+	assert.Equal(t, "abc", e.Value)
+	assert.Equal(t, "count", e.Long)
+	assert.Equal(t, "c", e.Short)
+
+	var numErr *strconv.NumError
+	assert.True(t, errors.As(err, &numErr))
+	assert.Equal(t, `error processing --count=abc: strconv.ParseInt: parsing "abc": invalid syntax`, err.Error())
+}
+
+func TestValueParseErrorFromEnv(t *testing.T) {
+	var args struct {
+		Count int `arg:"env:PARSE_ERROR_COUNT"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"PARSE_ERROR_COUNT=abc"}, &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, OriginEnv, e.Origin)
+	assert.Empty(t, e.Arg)
+	assert.Equal(t, "abc", e.Value)
+	assert.Equal(t, "PARSE_ERROR_COUNT", e.Env)
+	assert.Equal(t, `error processing environment variable PARSE_ERROR_COUNT: strconv.ParseInt: parsing "abc": invalid syntax`, err.Error())
+}
+
+func TestValueParseErrorFromEnvWithMultipleValues(t *testing.T) {
+	var args struct {
+		Counts []int `arg:"env:PARSE_ERROR_COUNTS"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"PARSE_ERROR_COUNTS=1,abc"}, &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, OriginEnv, e.Origin)
+	assert.Equal(t, "1,abc", e.Value)
+	assert.Equal(t, `error processing environment variable PARSE_ERROR_COUNTS with multiple values: strconv.ParseInt: parsing "abc": invalid syntax`, err.Error())
+}
+
+func TestValueParseErrorFromEnvForPositional(t *testing.T) {
+	var args struct {
+		Foo int `arg:"positional,env:PARSE_ERROR_FOO"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"PARSE_ERROR_FOO=notint"}, &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, OriginEnv, e.Origin)
+	assert.True(t, e.Positional)
+	assert.Equal(t, `error processing environment variable PARSE_ERROR_FOO: strconv.ParseInt: parsing "notint": invalid syntax`, err.Error())
+}
+
+func TestValueParseErrorFromPositional(t *testing.T) {
+	var args struct {
+		Count int `arg:"positional"`
+	}
+	err := parse("abc", &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.True(t, e.Positional)
+	assert.Equal(t, OriginCommandLine, e.Origin)
+	assert.Equal(t, "abc", e.Value)
+	assert.Equal(t, `error processing COUNT: strconv.ParseInt: parsing "abc": invalid syntax`, err.Error())
+}
+
+func TestCollectErrors(t *testing.T) {
+	var args struct {
+		Count int
+		Name  string `arg:"required"`
+		Input string `arg:"positional"`
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "--count=x --bogus a b", nil, &args)
+
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], `error processing --count=x: strconv.ParseInt: parsing "x": invalid syntax`)
+	assert.EqualError(t, errs[1], "unknown argument --bogus")
+	assert.EqualError(t, errs[2], "too many positional arguments at 'b'")
+	assert.EqualError(t, errs[3], "NAME is required")
+
+	var unknown *UnknownArgumentError
+	require.True(t, errors.As(err, &unknown))
+	assert.Equal(t, "--bogus", unknown.Arg)
+
+	var required *RequiredArgumentError
+	require.True(t, errors.As(err, &required))
+	assert.Equal(t, "name", required.Long)
+}
+
+func TestCollectErrorsNoErrors(t *testing.T) {
+	var args struct {
+		Count int
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "--count 3", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, 3, args.Count)
+}
+
+func TestCollectErrorsFromEnv(t *testing.T) {
+	var args struct {
+		A int `arg:"env:COLLECT_ERRORS_A"`
+		B int `arg:"env:COLLECT_ERRORS_B"`
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "", []string{"COLLECT_ERRORS_A=x", "COLLECT_ERRORS_B=y"}, &args)
+
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	assert.Len(t, errs, 2)
+}
+
+func TestCollectErrorsStopsAtInvalidSubcommand(t *testing.T) {
+	type getCmd struct{}
+	var args struct {
+		Count int
+		Get   *getCmd `arg:"subcommand"`
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "--count x put --foo", nil, &args)
+
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	var invalid *InvalidSubcommandError
+	assert.True(t, errors.As(errs[1], &invalid))
+}
+
+func TestWithoutCollectErrors(t *testing.T) {
+	var args struct {
+		Count int
+		Name  string `arg:"required"`
+	}
+	err := parse("--count=x", &args)
+
+	var errs Errors
+	assert.False(t, errors.As(err, &errs))
+	var e *ValueParseError
+	assert.True(t, errors.As(err, &e))
+}
+
+func TestErrorsMessage(t *testing.T) {
+	errs := Errors{errors.New("first"), errors.New("second")}
+	assert.Equal(t, "first\nsecond", errs.Error())
+}
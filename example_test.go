@@ -164,11 +164,13 @@ func Example_helpText() {
 	// This is only necessary when running inside golang's runnable example harness
 	mustParseExit = func(int) {}
 	mustParseOut = os.Stdout
+	mustParseHelpWidth = 80
 
 	MustParse(&args)
 
 	// output:
-	// Usage: example [--verbose] [--dataset DATASET] [--optim OPTIM] INPUT [OUTPUT [OUTPUT ...]]
+	// Usage: example [--verbose] [--dataset DATASET] [--optim OPTIM] INPUT
+	//                [OUTPUT [OUTPUT ...]]
 	//
 	// Positional arguments:
 	//   INPUT
@@ -197,6 +199,7 @@ func Example_helpPlaceholder() {
 	// This is only necessary when running inside golang's runnable example harness
 	mustParseExit = func(int) {}
 	mustParseOut = os.Stdout
+	mustParseHelpWidth = 80
 
 	MustParse(&args)
 
@@ -237,6 +240,7 @@ func Example_helpTextWithSubcommand() {
 	// This is only necessary when running inside golang's runnable example harness
 	mustParseExit = func(int) {}
 	mustParseOut = os.Stdout
+	mustParseHelpWidth = 80
 
 	MustParse(&args)
 
@@ -275,6 +279,7 @@ func Example_helpTextWhenUsingSubcommand() {
 	// This is only necessary when running inside golang's runnable example harness
 	mustParseExit = func(int) {}
 	mustParseOut = os.Stdout
+	mustParseHelpWidth = 80
 
 	MustParse(&args)
 
@@ -312,7 +317,7 @@ func Example_writeHelpForSubcommand() {
 	// This is only necessary when running inside golang's runnable example harness
 	exit := func(int) {}
 
-	p, err := NewParser(Config{Exit: exit}, &args)
+	p, err := NewParser(Config{Exit: exit, HelpWidth: 80}, &args)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -360,7 +365,7 @@ func Example_writeHelpForSubcommandNested() {
 	// This is only necessary when running inside golang's runnable example harness
 	exit := func(int) {}
 
-	p, err := NewParser(Config{Exit: exit}, &args)
+	p, err := NewParser(Config{Exit: exit, HelpWidth: 80}, &args)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -396,11 +401,13 @@ func Example_errorText() {
 	// This is only necessary when running inside golang's runnable example harness
 	mustParseExit = func(int) {}
 	mustParseOut = os.Stdout
+	mustParseHelpWidth = 80
 
 	MustParse(&args)
 
 	// output:
-	// Usage: example [--verbose] [--dataset DATASET] [--optimize OPTIMIZE] INPUT [OUTPUT [OUTPUT ...]]
+	// Usage: example [--verbose] [--dataset DATASET] [--optimize OPTIMIZE] INPUT
+	//                [OUTPUT [OUTPUT ...]]
 	// error: error processing --optimize: strconv.ParseInt: parsing "INVALID": invalid syntax
 }
 
@@ -420,6 +427,7 @@ func Example_errorTextForSubcommand() {
 	// This is only necessary when running inside golang's runnable example harness
 	mustParseExit = func(int) {}
 	mustParseOut = os.Stdout
+	mustParseHelpWidth = 80
 
 	MustParse(&args)
 
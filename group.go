@@ -0,0 +1,105 @@
+package arg
+
+import (
+	"strings"
+)
+
+// checkGroups checks that at most one option from each exclusive group was
+// provided, and that exactly one option from each one-required group was
+// provided. Only the active specs of the selected commands are considered.
+func (p *Parser) checkGroups(cmds []*command, active []*spec, wasPresent map[*spec]bool) error {
+	isActive := make(map[*spec]bool)
+	for _, spec := range active {
+		isActive[spec] = true
+	}
+
+	var errs Errors
+	for _, cmd := range cmds {
+		for _, g := range cmd.groups {
+			var options []Option
+			var provided []*spec
+			for _, spec := range g.specs {
+				if !isActive[spec] {
+					continue
+				}
+				options = append(options, optionOf(spec))
+				if wasPresent[spec] {
+					provided = append(provided, spec)
+				}
+			}
+			if len(options) == 0 {
+				continue
+			}
+
+			var err error
+			switch {
+			case len(provided) > 1:
+				e := &GroupConflictError{Group: g.name, Subcommand: p.subcommandPath()}
+				for _, spec := range provided {
+					e.Options = append(e.Options, optionOf(spec))
+					e.Args = append(e.Args, p.describeProvided(spec))
+				}
+				err = e
+			case len(provided) == 0 && g.oneRequired:
+				err = &GroupRequiredError{Group: g.name, Options: options, Subcommand: p.subcommandPath()}
+			default:
+				continue
+			}
+
+			if !p.config.CollectErrors {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// describeProvided describes how an option was provided, such as "--json" or
+// "environment variable FORMAT", for use in error messages
+func (p *Parser) describeProvided(spec *spec) string {
+	origin := p.origins[spec]
+	switch origin.Kind {
+	case OriginCommandLine:
+		if origin.Arg == "" {
+			return spec.placeholder
+		}
+		if strings.HasPrefix(origin.Arg, "--") {
+			return strings.SplitN(origin.Arg, "=", 2)[0]
+		}
+		if spec.short != "" {
+			return "-" + spec.short
+		}
+		return strings.SplitN(origin.Arg, "=", 2)[0]
+	case OriginEnv:
+		return "environment variable " + origin.Env
+	case OriginConfigFile:
+		return origin.Key + " in config file " + origin.File
+	default:
+		return optionName(optionOf(spec))
+	}
+}
+
+// optionName gets the name by which an option is known on the command line
+func optionName(opt Option) string {
+	switch {
+	case opt.Long != "":
+		return "--" + opt.Long
+	case opt.Short != "":
+		return "-" + opt.Short
+	default:
+		return opt.Placeholder
+	}
+}
+
+// joinList joins a list of names in the form "a, b and c"
+func joinList(names []string, conjunction string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " " + conjunction + " " + names[len(names)-1]
+}
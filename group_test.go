@@ -0,0 +1,142 @@
+package arg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type outputFormatArgs struct {
+	JSON  bool `arg:"-j,group:output,exclusive"`
+	YAML  bool `arg:"group:output"`
+	Table bool `arg:"group:output"`
+}
+
+func TestExclusiveGroup(t *testing.T) {
+	var args outputFormatArgs
+	err := parse("--yaml", &args)
+	require.NoError(t, err)
+	assert.True(t, args.YAML)
+
+	err = parse("", &args)
+	require.NoError(t, err)
+}
+
+func TestExclusiveGroupConflict(t *testing.T) {
+	var args outputFormatArgs
+	err := parse("-j --table", &args)
+
+	var e *GroupConflictError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "output", e.Group)
+	assert.Equal(t, []string{"-j", "--table"}, e.Args)
+	require.Len(t, e.Options, 2)
+	assert.Equal(t, "json", e.Options[0].Long)
+	assert.EqualError(t, err, "-j and --table cannot be used together")
+
+	err = parse("--json --yaml=true --table", &args)
+	assert.EqualError(t, err, "--json, --yaml and --table cannot be used together")
+}
+
+func TestExclusiveGroupConflictWithAbbreviation(t *testing.T) {
+	var args outputFormatArgs
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "--js --tab=true", nil, &args)
+	assert.EqualError(t, err, "--js and --tab cannot be used together")
+
+	var e *GroupConflictError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, []string{"--js", "--tab"}, e.Args)
+	assert.Equal(t, "json", e.Options[0].Long)
+	assert.Equal(t, "table", e.Options[1].Long)
+}
+
+func TestExclusiveGroupConflictWithEnv(t *testing.T) {
+	var args struct {
+		JSON bool `arg:"group:output,exclusive,env:GROUP_JSON"`
+		YAML bool `arg:"group:output"`
+	}
+	_, err := parseWithEnv(Config{}, "--yaml", []string{"GROUP_JSON=true"}, &args)
+	assert.EqualError(t, err, "environment variable GROUP_JSON and --yaml cannot be used together")
+}
+
+func TestOneRequiredGroup(t *testing.T) {
+	var args struct {
+		File string `arg:"group:input,one-required"`
+		URL  string `arg:"group:input"`
+	}
+	err := parse("--url http://example.com", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "http://example.com", args.URL)
+
+	err = parse("", &args)
+	var e *GroupRequiredError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "input", e.Group)
+	assert.EqualError(t, err, "one of --file or --url is required")
+
+	err = parse("--file x --url y", &args)
+	assert.EqualError(t, err, "--file and --url cannot be used together")
+}
+
+func TestOneRequiredGroupIgnoresDefault(t *testing.T) {
+	var args struct {
+		File string `arg:"group:input,one-required" default:"in.txt"`
+		URL  string `arg:"group:input"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, "one of --file or --url is required")
+}
+
+func TestGroupInSubcommand(t *testing.T) {
+	type exportCmd struct {
+		JSON bool `arg:"group:format,exclusive"`
+		CSV  bool `arg:"group:format"`
+	}
+	var args struct {
+		Export *exportCmd `arg:"subcommand"`
+	}
+	_, err := pparse("export --json --csv", &args)
+	var e *GroupConflictError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, []string{"export"}, e.Subcommand)
+
+	_, err = pparse("", &args)
+	require.NoError(t, err)
+}
+
+func TestGroupCollectErrors(t *testing.T) {
+	var args struct {
+		JSON bool   `arg:"group:output,exclusive"`
+		YAML bool   `arg:"group:output"`
+		File string `arg:"group:input,one-required"`
+		URL  string `arg:"group:input"`
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "--json --yaml", nil, &args)
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "--json and --yaml cannot be used together")
+	assert.EqualError(t, errs[1], "one of --file or --url is required")
+}
+
+func TestGroupTagErrors(t *testing.T) {
+	var missingGroup struct {
+		JSON bool `arg:"exclusive"`
+	}
+	err := parse("", &missingGroup)
+	assert.EqualError(t, err, ".JSON: exclusive and one-required can only be used with a group")
+
+	var positional struct {
+		Input string `arg:"positional,group:input,one-required"`
+	}
+	err = parse("", &positional)
+	assert.Error(t, err)
+
+	var plain struct {
+		JSON bool `arg:"group:output"`
+	}
+	err = parse("", &plain)
+	assert.EqualError(t, err, "args: group output must be exclusive or one-required")
+}
@@ -0,0 +1,276 @@
+package arg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteManPage writes a man page in roff format for the top-level command or for
+// a subcommand, which is given as a sequence of subcommand names in the same way
+// as for WriteHelpForSubcommand. The section is the man page section, such as "1"
+// for user commands or "8" for administration commands. The page for a subcommand
+// is named after the program and the subcommands joined with hyphens, such as
+// prog-remote-add.
+func (p *Parser) WriteManPage(w io.Writer, section string, subcommand ...string) error {
+	cmd, err := p.lookupCommand(subcommand...)
+	if err != nil {
+		return err
+	}
+
+	var positionals, options, envOnlyOptions []*spec
+	var hasVersionOption bool
+	for _, spec := range cmd.specs {
+		if spec.long == "version" {
+			hasVersionOption = true
+		}
+		if spec.hidden {
+			continue
+		}
+		switch {
+		case spec.positional:
+			positionals = append(positionals, spec)
+		case spec.long != "" || spec.short != "":
+			options = append(options, spec)
+		default:
+			envOnlyOptions = append(envOnlyOptions, spec)
+		}
+	}
+
+	// obtain a flattened list of options from all ancestors
+	var globals []*spec
+	for ancestor := cmd.parent; ancestor != nil; ancestor = ancestor.parent {
+		for _, spec := range ancestor.specs {
+			if spec.long == "version" {
+				hasVersionOption = true
+			}
+			if !spec.hidden && !spec.positional && (spec.long != "" || spec.short != "") {
+				globals = append(globals, spec)
+			}
+		}
+	}
+
+	// the built in options go at the end of the options
+	options = append(options, &spec{
+		cardinality: zero,
+		long:        "help",
+		short:       "h",
+		help:        "display this help and exit",
+	})
+	if !hasVersionOption && p.version != "" {
+		options = append(options, &spec{
+			cardinality: zero,
+			long:        "version",
+			help:        "display version and exit",
+		})
+	}
+
+	name := manPageName(p.cmd.name, subcommand)
+	summary := cmd.help
+	description := cmd.help
+	if cmd.parent == nil {
+		summary = strings.SplitN(p.description, "\n", 2)[0]
+		description = p.description
+	}
+
+	fmt.Fprintf(w, ".TH %s %s \"\" %s\n", roffQuote(strings.ToUpper(name)), roffQuote(section), roffQuote(p.version))
+
+	fmt.Fprintln(w, ".SH NAME")
+	if summary != "" {
+		fmt.Fprintf(w, "%s \\- %s\n", roffEscape(name), roffEscape(summary))
+	} else {
+		fmt.Fprintln(w, roffEscape(name))
+	}
+
+	// the synopsis is the usage string without the "Usage:" prefix, on one line
+	// since man does its own filling
+	var usage bytes.Buffer
+	p.writeUsage(&usage, 0, subcommand...)
+	synopsis := strings.TrimSpace(strings.TrimPrefix(usage.String(), "Usage:"))
+	invocation := strings.Join(append([]string{p.cmd.name}, subcommand...), " ")
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintf(w, ".B %s\n", roffQuote(invocation))
+	if rest := strings.TrimSpace(strings.TrimPrefix(synopsis, invocation)); rest != "" {
+		fmt.Fprintln(w, roffEscape(rest))
+	}
+
+	if description != "" {
+		fmt.Fprintln(w, ".SH DESCRIPTION")
+		writeRoffText(w, description)
+	}
+
+	if len(positionals) > 0 {
+		fmt.Fprintln(w, ".SH ARGUMENTS")
+		for _, spec := range positionals {
+			writeRoffItem(w, roffFont("I", spec.placeholder), spec)
+		}
+	}
+
+	fmt.Fprintln(w, ".SH OPTIONS")
+	for _, spec := range options {
+		writeRoffItem(w, roffOptionForms(spec), spec)
+	}
+
+	if len(globals) > 0 {
+		fmt.Fprintln(w, ".SH GLOBAL OPTIONS")
+		for _, spec := range globals {
+			writeRoffItem(w, roffOptionForms(spec), spec)
+		}
+	}
+
+	if len(envOnlyOptions) > 0 {
+		fmt.Fprintln(w, ".SH ENVIRONMENT")
+		for _, spec := range envOnlyOptions {
+			writeRoffItem(w, roffFont("B", spec.env), spec)
+		}
+	}
+
+	var subcommands []*command
+	for _, subcmd := range cmd.subcommands {
+		if !subcmd.hidden {
+			subcommands = append(subcommands, subcmd)
+		}
+	}
+	if len(subcommands) > 0 {
+		fmt.Fprintln(w, ".SH COMMANDS")
+		for _, subcmd := range subcommands {
+			fmt.Fprintln(w, ".TP")
+			fmt.Fprintln(w, roffFont("B", strings.Join(append([]string{subcmd.name}, subcmd.aliases...), ", ")))
+			if subcmd.help != "" {
+				writeRoffText(w, subcmd.help)
+			}
+			page := manPageName(p.cmd.name, append(append([]string{}, subcommand...), subcmd.name))
+			fmt.Fprintf(w, "See %s(%s).\n", roffFont("B", page), roffEscape(section))
+		}
+	}
+
+	if p.epilogue != "" {
+		fmt.Fprintln(w, ".SH NOTES")
+		writeRoffText(w, p.epilogue)
+	}
+	return nil
+}
+
+// WriteManPages writes a man page for the top-level command and for each visible
+// subcommand into a directory, with file names such as prog.1 and prog-remote.1
+func (p *Parser) WriteManPages(dir, section string) error {
+	var write func(cmd *command, path []string) error
+	write = func(cmd *command, path []string) error {
+		var buf bytes.Buffer
+		if err := p.WriteManPage(&buf, section, path...); err != nil {
+			return err
+		}
+		filename := filepath.Join(dir, manPageName(p.cmd.name, path)+"."+section)
+		if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		for _, subcmd := range cmd.subcommands {
+			if subcmd.hidden {
+				continue
+			}
+			if err := write(subcmd, append(append([]string{}, path...), subcmd.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return write(p.cmd, nil)
+}
+
+// manPageName gets the name of the man page for a command
+func manPageName(program string, subcommand []string) string {
+	return strings.Join(append([]string{program}, subcommand...), "-")
+}
+
+// writeRoffItem writes a tagged paragraph for an option, positional, or
+// environment variable, with its default value and environment variable
+func writeRoffItem(w io.Writer, tag string, spec *spec) {
+	fmt.Fprintln(w, ".TP")
+	fmt.Fprintln(w, tag)
+
+	var extras []string
+	if spec.defaultString != "" {
+		extras = append(extras, "Default: "+spec.defaultString+".")
+	}
+	if spec.env != "" && (spec.long != "" || spec.short != "" || spec.positional) {
+		extras = append(extras, "Environment variable: "+spec.env+".")
+	}
+	if len(spec.choices) > 0 {
+		extras = append(extras, "Choices: "+strings.Join(spec.choices, ", ")+".")
+	}
+
+	text := spec.help
+	if len(extras) > 0 {
+		if text != "" {
+			text += "\n"
+		}
+		text += strings.Join(extras, " ")
+	}
+	if text != "" {
+		writeRoffText(w, text)
+	}
+}
+
+// roffOptionForms gets the forms of an option in bold with its placeholder in
+// italics, such as \fB\-\-name\fR \fINAME\fR, \fB\-n\fR \fINAME\fR
+func roffOptionForms(spec *spec) string {
+	var forms []string
+	if spec.long != "" && spec.negatable {
+		forms = append(forms, roffFont("B", "--[no-]"+spec.long))
+	} else if spec.long != "" {
+		forms = append(forms, roffSynopsis(spec, "--"+spec.long))
+	}
+	if spec.short != "" {
+		forms = append(forms, roffSynopsis(spec, "-"+spec.short))
+	}
+	return strings.Join(forms, ", ")
+}
+
+// roffSynopsis gets the synopsis of one form of an option with the form in bold
+// and the rest in italics
+func roffSynopsis(spec *spec, form string) string {
+	rest := strings.TrimPrefix(synopsis(spec, form), form)
+	if rest == "" {
+		return roffFont("B", form)
+	}
+	if strings.HasPrefix(rest, " ") {
+		return roffFont("B", form) + " " + roffFont("I", rest[1:])
+	}
+	return roffFont("B", form) + roffFont("I", rest)
+}
+
+// roffFont writes text in a font such as "B" for bold or "I" for italics
+func roffFont(font, s string) string {
+	return `\f` + font + roffEscape(s) + `\fR`
+}
+
+// writeRoffText writes text with each line of the original on its own line,
+// separated by line breaks
+func writeRoffText(w io.Writer, s string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			fmt.Fprintln(w, ".br")
+		}
+		fmt.Fprintln(w, roffEscape(line))
+	}
+}
+
+// roffEscape escapes backslashes and hyphens, and protects lines that would
+// otherwise be taken as roff requests
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+// roffQuote escapes a string and puts it in double quotes for use as an argument
+// to a roff request
+func roffQuote(s string) string {
+	return `"` + strings.ReplaceAll(roffEscape(s), `"`, `""`) + `"`
+}
@@ -0,0 +1,132 @@
+package arg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type manAdd struct {
+	Name  string `arg:"positional,required" help:"name of the remote"`
+	Fetch bool   `arg:"-f" help:"fetch after adding"`
+}
+
+type manRemote struct {
+	Add *manAdd `arg:"subcommand:add" help:"add a remote"`
+}
+
+type manArgs struct {
+	Verbose bool       `arg:"-v" help:"be verbose"`
+	Level   string     `default:"info" choices:"debug|info" help:"log level"`
+	Output  string     `arg:"env:OUTPUT" help:"where to write"`
+	Token   string     `arg:"--,env:API_TOKEN" help:"the API token"`
+	Secret  string     `arg:"hidden"`
+	Remote  *manRemote `arg:"subcommand:remote" help:"manage remotes"`
+	Debug   *struct{}  `arg:"subcommand:debug,hidden"`
+}
+
+func (manArgs) Description() string { return "example manages things\nin several ways" }
+func (manArgs) Epilogue() string    { return "For more information visit example.com" }
+func (manArgs) Version() string     { return "example 1.2.3" }
+
+func TestWriteManPage(t *testing.T) {
+	expected := `.TH "EXAMPLE" "1" "" "example 1.2.3"
+.SH NAME
+example \- example manages things
+.SH SYNOPSIS
+.B "example"
+[\-\-verbose] [\-\-level LEVEL] [\-\-output OUTPUT] <command> [<args>]
+.SH DESCRIPTION
+example manages things
+.br
+in several ways
+.SH OPTIONS
+.TP
+\fB\-\-verbose\fR, \fB\-v\fR
+be verbose
+.TP
+\fB\-\-level\fR \fILEVEL\fR
+log level
+.br
+Default: info. Choices: debug, info.
+.TP
+\fB\-\-output\fR \fIOUTPUT\fR
+where to write
+.br
+Environment variable: OUTPUT.
+.TP
+\fB\-\-help\fR, \fB\-h\fR
+display this help and exit
+.TP
+\fB\-\-version\fR
+display version and exit
+.SH ENVIRONMENT
+.TP
+\fBAPI_TOKEN\fR
+the API token
+.SH COMMANDS
+.TP
+\fBremote\fR
+manage remotes
+See \fBexample\-remote\fR(1).
+.SH NOTES
+For more information visit example.com
+`
+	var args manArgs
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteManPage(&b, "1"))
+	assert.Equal(t, expected, b.String())
+}
+
+func TestWriteManPageForSubcommand(t *testing.T) {
+	var args manArgs
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, p.WriteManPage(&b, "8", "remote", "add"))
+	page := b.String()
+	assert.Contains(t, page, ".TH \"EXAMPLE\\-REMOTE\\-ADD\" \"8\" \"\" \"example 1.2.3\"\n")
+	assert.Contains(t, page, ".SH NAME\nexample\\-remote\\-add \\- add a remote\n")
+	assert.Contains(t, page, ".SH SYNOPSIS\n.B \"example remote add\"\n[\\-\\-fetch] NAME\n")
+	assert.Contains(t, page, ".SH ARGUMENTS\n.TP\n\\fINAME\\fR\nname of the remote\n")
+	assert.Contains(t, page, ".SH GLOBAL OPTIONS\n.TP\n\\fB\\-\\-verbose\\fR, \\fB\\-v\\fR\n")
+	assert.NotContains(t, page, ".SH COMMANDS")
+
+	err = p.WriteManPage(&b, "1", "nope")
+	assert.Error(t, err)
+}
+
+func TestRoffEscape(t *testing.T) {
+	assert.Equal(t, `\&.start`, roffEscape(".start"))
+	assert.Equal(t, `\&'quote`, roffEscape("'quote"))
+	assert.Equal(t, `a\ec \-\-x`, roffEscape(`a\c --x`))
+}
+
+func TestWriteManPages(t *testing.T) {
+	var args manArgs
+	p, err := NewParser(Config{Program: "example"}, &args)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, p.WriteManPages(dir, "1"))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"example-remote-add.1", "example-remote.1", "example.1"}, names)
+
+	buf, err := os.ReadFile(filepath.Join(dir, "example-remote.1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(buf), "See \\fBexample\\-remote\\-add\\fR(1).\n")
+}
@@ -9,7 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	scalar "github.com/alexflint/go-scalar"
 )
@@ -44,20 +46,34 @@ func (p path) Child(f reflect.StructField) path {
 
 // spec represents a command line option
 type spec struct {
-	dest          path
-	field         reflect.StructField // the struct field from which this option was created
-	long          string              // the --long form for this option, or empty if none
-	short         string              // the -s short form for this option, or empty if none
-	cardinality   cardinality         // determines how many tokens will be present (possible values: zero, one, multiple)
-	required      bool                // if true, this option must be present on the command line
-	positional    bool                // if true, this option will be looked for in the positional flags
-	separate      bool                // if true, each slice and map entry will have its own --flag
-	help          string              // the help text for this option
-	hidden        bool                // if true, this option will be hidden from help text
-	env           string              // the name of the environment variable for this option, or empty for none
-	defaultValue  reflect.Value       // default value for this option
-	defaultString string              // default value for this option, in string form to be displayed in help text
-	placeholder   string              // placeholder string in help
+	dest           path
+	field          reflect.StructField // the struct field from which this option was created
+	long           string              // the --long form for this option, or empty if none
+	short          string              // the -s short form for this option, or empty if none
+	cardinality    cardinality         // determines how many tokens will be present (possible values: zero, one, multiple)
+	required       bool                // if true, this option must be present on the command line
+	positional     bool                // if true, this option will be looked for in the positional flags
+	separate       bool                // if true, each slice and map entry will have its own --flag
+	counter        bool                // if true, this integer option is incremented each time it appears
+	negatable      bool                // if true, this boolean option can be set to false with --no-<long>
+	optionalValue  bool                // if true, this option may appear without a value, in which case implicit is used
+	implicit       string              // the value used when an option with an optional value appears without one
+	minValues      int                 // the minimum number of values for an option with fixed arity
+	maxValues      int                 // the maximum number of values for an option with fixed arity, or zero if not fixed
+	configKey      string              // the key for this option in config files, or empty for none
+	configFile     bool                // if true, this option holds the path to a config file
+	commandPath    []string            // the names of the subcommands under which this option is defined
+	group          *group              // the group to which this option belongs, or nil for none
+	dependencies   []*dependency       // relationships with other options from the requires, required-if, and required-unless tags
+	choices        []string            // the values that this option accepts, or nil for any value
+	validation     []*validationRule   // the rules from the validate tag
+	help           string              // the help text for this option
+	hidden         bool                // if true, this option will be hidden from help text
+	env            string              // the name of the environment variable for this option, or empty for none
+	defaultValue   reflect.Value       // default value for this option
+	initialDefault bool                // if true, defaultValue is the initial value of the field rather than from a default tag
+	defaultString  string              // default value for this option, in string form to be displayed in help text
+	placeholder    string              // placeholder string in help
 }
 
 // command represents a named subcommand, or the top-level command
@@ -68,23 +84,45 @@ type command struct {
 	dest        path
 	specs       []*spec
 	subcommands []*command
+	groups      []*group
 	parent      *command
 	hidden      bool
 }
 
+// dependency represents a relationship between an option and other options
+type dependency struct {
+	rule  string   // the tag that created this dependency: requires, required-if, or required-unless
+	names []string // the long names or field names of the other options, as given in the tag
+	specs []*spec  // the other options, found by NewParser
+}
+
+// group represents a set of options that are checked together
+type group struct {
+	name        string
+	exclusive   bool    // if true, at most one of the options may be provided
+	oneRequired bool    // if true, exactly one of the options must be provided
+	specs       []*spec // the options in this group
+}
+
 // ErrHelp indicates that the builtin -h or --help were provided
 var ErrHelp = errors.New("help requested by user")
 
 // ErrVersion indicates that the builtin --version was provided
 var ErrVersion = errors.New("version requested by user")
 
+// ErrCompletion indicates that the builtin --completion was provided, or that the
+// first argument was the builtin __complete, which are only recognized when
+// Config.CompletionFlag is set
+var ErrCompletion = errors.New("completion script requested by user")
+
 // for monkey patching in example and test code
 var mustParseExit = os.Exit
 var mustParseOut io.Writer = os.Stdout
+var mustParseHelpWidth int
 
 // MustParse processes command line arguments and exits upon failure
 func MustParse(dest ...interface{}) *Parser {
-	return mustParse(Config{Exit: mustParseExit, Out: mustParseOut}, dest...)
+	return mustParse(Config{Exit: mustParseExit, Out: mustParseOut, HelpWidth: mustParseHelpWidth}, dest...)
 }
 
 // mustParse is a helper that facilitates testing
@@ -145,6 +183,51 @@ type Config struct {
 	// By default, the environment variable would be the name of the field converted to uppercase. Use DefaultEnvName to dynamically set the name of the environment variables instead.
 	AllHaveEnv bool
 
+	// AllowAbbreviations instructs the library to accept any unambiguous prefix of a long
+	// option name, so that --verb is understood as --verbose.
+	AllowAbbreviations bool
+
+	// AllowSubcommandAbbreviations instructs the library to accept any unambiguous prefix
+	// of a subcommand name or alias.
+	AllowSubcommandAbbreviations bool
+
+	// ConfigFiles is a list of JSON config files from which to read values for options that
+	// were not given on the command line or through environment variables. Files that do
+	// not exist are skipped, and later files take precedence over earlier ones.
+	ConfigFiles []string
+
+	// Sources is a list of additional places from which to read values for options that
+	// were not given on the command line. Each source is consulted in order of priority,
+	// together with the built-in sources for environment variables (EnvPriority), config
+	// files (ConfigFilePriority), and default values (DefaultPriority), and the first
+	// source that has a value for an option wins.
+	Sources []PrioritizedSource
+
+	// ResponseFilePrefix, if not empty, instructs the library to replace each argument
+	// that starts with this prefix, such as "@args.txt" for the prefix "@", with the
+	// arguments read from the named file
+	ResponseFilePrefix string
+
+	// CollectErrors instructs the library to continue past problems such as unknown
+	// arguments, invalid values, and missing required options, and to return all of
+	// them together as an Errors value.
+	CollectErrors bool
+
+	// CompletionFlag instructs the library to accept a hidden --completion SHELL builtin
+	// option, which causes MustParse to print a completion script for the given shell
+	// and exit, and a hidden __complete command, which causes MustParse to print the
+	// candidates for the last argument and exit. See WriteCompletion and Complete.
+	CompletionFlag bool
+
+	// NegatableFlags instructs the library to accept --no-<name> for every boolean option
+	// that has a long name, as if each one had the "negatable" tag.
+	NegatableFlags bool
+
+	// HelpWidth is the width in columns to which help and usage text is wrapped. If it
+	// is zero then the width is taken from the COLUMNS environment variable, or is 80
+	// if that is not set.
+	HelpWidth int
+
 	// Exit is called to terminate the process with an error code (defaults to os.Exit)
 	Exit func(int)
 
@@ -161,8 +244,12 @@ type Parser struct {
 	description string
 	epilogue    string
 
-	// the following field changes during processing of command line arguments
-	subcommand []string
+	// the following fields change during processing of command line arguments
+	subcommand      []string
+	origins         map[*spec]Origin
+	completionShell string   // the shell given to the builtin --completion
+	completionWords []string // the words given to the builtin __complete
+	completing      bool     // process is filling in values for Complete
 }
 
 // Versioned is the interface that the destination struct should implement to
@@ -189,6 +276,14 @@ type Epilogued interface {
 	Epilogue() string
 }
 
+// Validator is the interface that the destination struct, an embedded struct, or
+// a subcommand struct can implement to check the parsed values as a whole.
+type Validator interface {
+	// Validate returns an error if the values in the struct are not valid. It is
+	// called after all the options have been parsed and checked.
+	Validate() error
+}
+
 // walkFields calls a function for each field of a struct, recursively expanding struct fields.
 func walkFields(t reflect.Type, visit func(field reflect.StructField, owner reflect.Type) bool) {
 	walkFieldsImpl(t, visit, nil)
@@ -268,6 +363,7 @@ func NewParser(config Config, dests ...interface{}) (*Parser, error) {
 
 			// store as a default
 			spec.defaultValue = v
+			spec.initialDefault = true
 
 			// we need a string to display in help text
 			// if MarshalText is implemented then use that
@@ -284,6 +380,7 @@ func NewParser(config Config, dests ...interface{}) (*Parser, error) {
 
 		p.cmd.specs = append(p.cmd.specs, cmd.specs...)
 		p.cmd.subcommands = append(p.cmd.subcommands, cmd.subcommands...)
+		p.cmd.groups = append(p.cmd.groups, cmd.groups...)
 
 		if dest, ok := dest.(Versioned); ok {
 			p.version = dest.Version()
@@ -303,9 +400,63 @@ func NewParser(config Config, dests ...interface{}) (*Parser, error) {
 		subcommand.parent = p.cmd
 	}
 
+	// mark boolean options as negatable and check for conflicting --no-<name> forms
+	if err := setupNegatable(p.cmd, config.NegatableFlags, nil); err != nil {
+		return nil, err
+	}
+
+	// record the subcommand under which each option is defined
+	setCommandPaths(p.cmd, nil)
+
+	// find the options referred to by requires, required-if, and required-unless
+	if err := resolveDependencies(p.cmd, nil); err != nil {
+		return nil, err
+	}
+
 	return &p, nil
 }
 
+// setupNegatable marks all boolean options in a command tree as negatable if
+// all is true, then checks that the --no-<name> form of each negatable option
+// does not collide with another option that is active at the same time.
+func setupNegatable(cmd *command, all bool, inherited []*spec) error {
+	if all {
+		for _, spec := range cmd.specs {
+			if spec.cardinality == zero && !spec.counter && !spec.positional && spec.long != "" {
+				spec.negatable = true
+			}
+		}
+	}
+
+	specs := append(append([]*spec{}, inherited...), cmd.specs...)
+	for _, spec := range specs {
+		if !spec.negatable {
+			continue
+		}
+		if other := findOption(specs, "no-"+spec.long); other != nil {
+			return fmt.Errorf("%s: --no-%s conflicts with the option for %s", spec.dest, spec.long, other.dest)
+		}
+	}
+
+	for _, subcmd := range cmd.subcommands {
+		if err := setupNegatable(subcmd, all, specs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCommandPaths stores in each spec the names of the subcommands leading to
+// the command in which it is defined
+func setCommandPaths(cmd *command, names []string) {
+	for _, spec := range cmd.specs {
+		spec.commandPath = names
+	}
+	for _, subcmd := range cmd.subcommands {
+		setCommandPaths(subcmd, append(append([]string{}, names...), subcmd.name))
+	}
+}
+
 func upperCaseFromFieldName(field reflect.StructField) string {
 	return strings.ToUpper(field.Name)
 }
@@ -373,6 +524,8 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 
 		// process each comma-separated part of the tag
 		var isSubcommand bool
+		var groupName string
+		var exclusive, oneRequired bool
 		for _, key := range strings.Split(tag, ",") {
 			if key == "" {
 				continue
@@ -406,6 +559,30 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 				}
 			case key == "separate":
 				spec.separate = true
+			case key == "counter":
+				spec.counter = true
+			case key == "negatable":
+				spec.negatable = true
+			case key == "optional-value":
+				spec.optionalValue = true
+			case key == "config":
+				spec.configFile = true
+			case key == "group":
+				groupName = value
+			case key == "exclusive":
+				exclusive = true
+			case key == "one-required":
+				oneRequired = true
+			case key == "requires", key == "required-if", key == "required-unless":
+				if value == "" {
+					errs = append(errs, fmt.Sprintf("%s.%s: %s must name at least one option",
+						t.Name(), field.Name, key))
+					return false
+				}
+				spec.dependencies = append(spec.dependencies, &dependency{
+					rule:  key,
+					names: strings.Split(value, "|"),
+				})
 			case key == "help": // deprecated
 				spec.help = value
 			case key == "hidden":
@@ -448,6 +625,19 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 			}
 		}
 
+		// the key for this option in config files is its long name unless overridden
+		// by the config tag, and "-" excludes the option from config files
+		spec.configKey = spec.long
+		if spec.configKey == "" {
+			spec.configKey = strings.ToLower(field.Name)
+		}
+		if configKey, hasConfigKey := field.Tag.Lookup("config"); hasConfigKey {
+			spec.configKey = configKey
+			if configKey == "-" {
+				spec.configKey = ""
+			}
+		}
+
 		// placeholder is the string used in the help text like this: "--somearg PLACEHOLDER"
 		placeholder, hasPlaceholder := field.Tag.Lookup("placeholder")
 		if hasPlaceholder {
@@ -478,6 +668,110 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 			return false
 		}
 
+		// counters are integers that behave like boolean flags on the command line
+		if spec.counter {
+			if !isInteger(field.Type) {
+				errs = append(errs, fmt.Sprintf("%s.%s: counters must be integer fields but this field is %s",
+					t.Name(), field.Name, field.Type.String()))
+				return false
+			}
+			if spec.positional {
+				errs = append(errs, fmt.Sprintf("%s.%s: counters cannot be positional",
+					t.Name(), field.Name))
+				return false
+			}
+			spec.cardinality = zero
+		}
+
+		// an option with an optional value uses its implicit value when it appears bare
+		implicit, hasImplicit := field.Tag.Lookup("implicit")
+		if hasImplicit {
+			spec.optionalValue = true
+			spec.implicit = implicit
+		}
+		if spec.optionalValue {
+			if spec.cardinality != one || spec.positional {
+				errs = append(errs, fmt.Sprintf("%s.%s: optional values are only supported for options that take a single value",
+					t.Name(), field.Name))
+				return false
+			}
+			if err := scalar.ParseValue(reflect.New(field.Type).Elem(), spec.implicit); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s: error processing implicit value: %v", t.Name(), field.Name, err))
+				return false
+			}
+		}
+
+		// arrays take exactly as many values as their length, and slices can be
+		// restricted to a fixed number of values with the nargs tag
+		nargs, hasNargs := field.Tag.Lookup("nargs")
+		if hasNargs {
+			elem := field.Type
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if spec.cardinality != multiple || elem.Kind() != reflect.Slice {
+				errs = append(errs, fmt.Sprintf("%s.%s: nargs is only supported for slice fields",
+					t.Name(), field.Name))
+				return false
+			}
+			spec.minValues, spec.maxValues, err = parseNargs(nargs)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s: %v", t.Name(), field.Name, err))
+				return false
+			}
+		} else if isArray(field.Type) {
+			elem := field.Type
+			if elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			spec.minValues, spec.maxValues = elem.Len(), elem.Len()
+		}
+		if spec.maxValues > 0 && (spec.positional || spec.separate) {
+			errs = append(errs, fmt.Sprintf("%s.%s: fixed-arity fields cannot be positional or separate",
+				t.Name(), field.Name))
+			return false
+		}
+
+		// the path to a config file must be a string
+		elem := field.Type
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if spec.configFile && (spec.positional || elem.Kind() != reflect.String) {
+			errs = append(errs, fmt.Sprintf("%s.%s: config must be used on a string option",
+				t.Name(), field.Name))
+			return false
+		}
+
+		// only boolean options with a long name have a --no-<name> form
+		if spec.negatable && (spec.cardinality != zero || spec.counter || spec.positional || spec.long == "") {
+			errs = append(errs, fmt.Sprintf("%s.%s: only boolean options with a long name can be negatable",
+				t.Name(), field.Name))
+			return false
+		}
+
+		// exclusive and one-required describe a group, and options in a group are
+		// checked together so they cannot be positional or individually required
+		if (exclusive || oneRequired) && groupName == "" {
+			errs = append(errs, fmt.Sprintf("%s.%s: exclusive and one-required can only be used with a group",
+				t.Name(), field.Name))
+			return false
+		}
+		if groupName != "" && (spec.positional || spec.required) {
+			errs = append(errs, fmt.Sprintf("%s.%s: positionals and required options cannot be in a group",
+				t.Name(), field.Name))
+			return false
+		}
+
+		// an option that is always required cannot also be conditionally required
+		for _, dep := range spec.dependencies {
+			if spec.required && dep.rule != "requires" {
+				errs = append(errs, fmt.Sprintf("%s.%s: 'required' cannot be used with %s",
+					t.Name(), field.Name, dep.rule))
+				return false
+			}
+		}
+
 		// record the existence of a slice or map that will consume all remaining
 		// positional arguments so that we can throw an error if further positionals
 		// are found later
@@ -519,6 +813,52 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 			}
 		}
 
+		// the choices tag or the Enumerated interface restricts the values of an option
+		if choices, hasChoices := field.Tag.Lookup("choices"); hasChoices {
+			spec.choices = strings.Split(choices, "|")
+		} else {
+			spec.choices = choicesOf(field.Type)
+		}
+		if spec.choices != nil {
+			if err := checkChoices(&spec); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s: %v", t.Name(), field.Name, err))
+				return false
+			}
+			if hasDefault && !contains(spec.choices, defaultString) {
+				errs = append(errs, fmt.Sprintf("%s.%s: default value %q is not one of the choices",
+					t.Name(), field.Name, defaultString))
+				return false
+			}
+		}
+
+		// the validate tag lists rules that the value must satisfy after parsing
+		if validate, hasValidate := field.Tag.Lookup("validate"); hasValidate {
+			rules, err := parseValidateTag(validate, field.Type)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s.%s: %v", t.Name(), field.Name, err))
+				return false
+			}
+			spec.validation = rules
+		}
+
+		// add the spec to its group, creating the group if necessary
+		if groupName != "" {
+			var g *group
+			for _, existing := range cmd.groups {
+				if existing.name == groupName {
+					g = existing
+				}
+			}
+			if g == nil {
+				g = &group{name: groupName}
+				cmd.groups = append(cmd.groups, g)
+			}
+			g.exclusive = g.exclusive || exclusive
+			g.oneRequired = g.oneRequired || oneRequired
+			g.specs = append(g.specs, &spec)
+			spec.group = g
+		}
+
 		// add the spec to the list of specs
 		cmd.specs = append(cmd.specs, &spec)
 
@@ -526,6 +866,13 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 		return false
 	})
 
+	// check that each group says how its options are checked
+	for _, g := range cmd.groups {
+		if !g.exclusive && !g.oneRequired {
+			errs = append(errs, fmt.Sprintf("%s: group %s must be exclusive or one-required", dest, g.name))
+		}
+	}
+
 	if len(errs) > 0 {
 		return nil, errors.New(strings.Join(errs, "\n"))
 	}
@@ -549,11 +896,26 @@ func cmdFromStruct(name string, dest path, t reflect.Type, envPrefix string, def
 //
 // It returns ErrHelp if "--help" is one of the command line args and ErrVersion if
 // "--version" is one of the command line args (the latter only applies if the
-// destination struct passed to NewParser implements Versioned.)
+// destination struct passed to NewParser implements Versioned.) It returns
+// ErrCompletion for the builtins that are enabled by Config.CompletionFlag.
 //
 // To respond to --help and --version in the way that MustParse does, see examples
 // in the README under "Custom handling of --help and --version".
 func (p *Parser) Parse(args []string) error {
+	p.completionShell, p.completionWords = "", nil
+	if p.config.CompletionFlag && len(args) > 0 && args[0] == "__complete" {
+		p.completionWords = args[1:]
+		return ErrCompletion
+	}
+
+	if p.config.ResponseFilePrefix != "" {
+		var err error
+		args, err = expandResponseFiles(args, p.config.ResponseFilePrefix)
+		if err != nil {
+			return err
+		}
+	}
+
 	err := p.process(args)
 	if err != nil {
 		// If -h or --help were specified then make sure help text supercedes other errors
@@ -570,7 +932,16 @@ func (p *Parser) Parse(args []string) error {
 }
 
 func (p *Parser) MustParse(args []string) {
-	err := p.Parse(args)
+	if err := p.Parse(args); err != nil {
+		p.failWithParseError(err)
+	}
+}
+
+// failWithParseError responds to an error from Parse in the way that MustParse
+// does, by printing help or version information and exiting with status 0, or
+// by printing usage information for the relevant subcommand and exiting with
+// status 2
+func (p *Parser) failWithParseError(err error) {
 	switch {
 	case err == ErrHelp:
 		p.WriteHelpForSubcommand(p.config.Out, p.subcommand...)
@@ -578,53 +949,54 @@ func (p *Parser) MustParse(args []string) {
 	case err == ErrVersion:
 		fmt.Fprintln(p.config.Out, p.version)
 		p.config.Exit(0)
+	case err == ErrCompletion && p.completionShell != "":
+		p.WriteCompletion(p.config.Out, p.completionShell)
+		p.config.Exit(0)
+	case err == ErrCompletion:
+		p.writeCandidates(p.config.Out, p.completionWords)
+		p.config.Exit(0)
 	case err != nil:
-		p.FailSubcommand(err.Error(), p.subcommand...)
+		var errs Errors
+		var verr *ValidatorError
+		if errors.As(err, &errs) && len(errs) > 1 {
+			p.failWithErrors(errs, p.subcommand...)
+		} else if errors.As(err, &verr) {
+			p.FailSubcommand(err.Error(), verr.Subcommand...)
+		} else {
+			p.FailSubcommand(err.Error(), p.subcommand...)
+		}
 	}
 }
 
-// process environment vars for the given arguments
-func (p *Parser) captureEnvVars(specs []*spec, wasPresent map[*spec]bool) error {
-	for _, spec := range specs {
-		if spec.env == "" {
-			continue
-		}
-
-		value, found := os.LookupEnv(spec.env)
-		if !found {
-			continue
+// captureEnvVar parses the value of the environment variable for a single spec
+func (p *Parser) captureEnvVar(spec *spec, value string) error {
+	if spec.cardinality != multiple {
+		if err := p.setScalar(spec, value); err != nil {
+			return p.envError(spec, value, err)
 		}
+		return nil
+	}
 
-		if spec.cardinality == multiple {
-			// expect a CSV string in an environment
-			// variable in the case of multiple values
-			var values []string
-			var err error
-			if len(strings.TrimSpace(value)) > 0 {
-				values, err = csv.NewReader(strings.NewReader(value)).Read()
-				if err != nil {
-					return fmt.Errorf(
-						"error reading a CSV string from environment variable %s with multiple values: %v",
-						spec.env,
-						err,
-					)
-				}
-			}
-			if err = setSliceOrMap(p.val(spec.dest), values, !spec.separate); err != nil {
-				return fmt.Errorf(
-					"error processing environment variable %s with multiple values: %v",
-					spec.env,
-					err,
-				)
-			}
-		} else {
-			if err := scalar.ParseValue(p.val(spec.dest), value); err != nil {
-				return fmt.Errorf("error processing environment variable %s: %v", spec.env, err)
-			}
+	// expect a CSV string in an environment
+	// variable in the case of multiple values
+	var values []string
+	var err error
+	if len(strings.TrimSpace(value)) > 0 {
+		values, err = csv.NewReader(strings.NewReader(value)).Read()
+		if err != nil {
+			return fmt.Errorf(
+				"error reading a CSV string from environment variable %s with multiple values: %v",
+				spec.env,
+				err,
+			)
 		}
-		wasPresent[spec] = true
 	}
-
+	if spec.maxValues > 0 && (len(values) < spec.minValues || len(values) > spec.maxValues) {
+		return p.countError(spec, "environment variable "+spec.env, spec.minValues, spec.maxValues, len(values))
+	}
+	if err = p.setMultiple(spec, values, !spec.separate); err != nil {
+		return p.envError(spec, value, err)
+	}
 	return nil
 }
 
@@ -633,35 +1005,71 @@ func (p *Parser) captureEnvVars(specs []*spec, wasPresent map[*spec]bool) error
 func (p *Parser) process(args []string) error {
 	// track the options we have seen
 	wasPresent := make(map[*spec]bool)
+	p.origins = make(map[*spec]Origin)
+
+	// present records that an option appeared on the command line at index i
+	present := func(spec *spec, i int, arg, value string) {
+		wasPresent[spec] = true
+		p.origins[spec] = Origin{Kind: OriginCommandLine, Value: value, Arg: arg, Index: i}
+	}
 
 	// union of specs for the chain of subcommands encountered so far
 	curCmd := p.cmd
+	cmds := []*command{curCmd}
 	p.subcommand = nil
 
 	// make a copy of the specs because we will add to this list each time we expand a subcommand
 	specs := make([]*spec, len(curCmd.specs))
 	copy(specs, curCmd.specs)
 
-	// deal with environment vars
-	if !p.config.IgnoreEnv {
-		err := p.captureEnvVars(specs, wasPresent)
-		if err != nil {
+	// when collecting errors, fail records a recoverable problem and returns nil so
+	// that processing continues, and abort adds a final unrecoverable problem
+	var errs Errors
+	fail := func(err error) error {
+		if err == nil || !p.config.CollectErrors {
 			return err
 		}
+		if list, ok := err.(Errors); ok {
+			errs = append(errs, list...)
+		} else {
+			errs = append(errs, err)
+		}
+		return nil
+	}
+	abort := func(err error) error {
+		if !p.config.CollectErrors {
+			return err
+		}
+		return append(errs, err)
 	}
 
-	// determine if the current command has a version option spec
-	var hasVersionOption bool
+	// counters that were only incremented on the command line, and so start from
+	// the value given by a source rather than from their defaults
+	increments := make(map[*spec]int)
+	count := func(spec *spec, i int, arg string) {
+		if !wasPresent[spec] || increments[spec] > 0 {
+			increments[spec]++
+		}
+		p.incrementCounter(spec, wasPresent[spec])
+		present(spec, i, arg, "")
+	}
+
+	// determine if the current command has a version or completion option spec
+	var hasVersionOption, hasCompletionOption bool
 	for _, spec := range curCmd.specs {
-		if spec.long == "version" {
+		switch spec.long {
+		case "version":
 			hasVersionOption = true
-			break
+		case "completion":
+			hasCompletionOption = true
 		}
 	}
+	hasCompletionFlag := p.config.CompletionFlag && !hasCompletionOption
 
 	// process each string from the command line
 	var allpositional bool
 	var positionals []string
+	var positionalIndices []int // the index in args of each positional
 
 	// must use explicit for loop, not range, because we manipulate i inside the loop
 	for i := 0; i < len(args); i++ {
@@ -675,13 +1083,24 @@ func (p *Parser) process(args []string) error {
 			// each subcommand can have either subcommands or positionals, but not both
 			if len(curCmd.subcommands) == 0 {
 				positionals = append(positionals, arg)
+				positionalIndices = append(positionalIndices, i)
 				continue
 			}
 
 			// if we have a subcommand then make sure it is valid for the current context
 			subcmd := findSubcommand(curCmd.subcommands, arg)
+			if subcmd == nil && p.config.AllowSubcommandAbbreviations {
+				var err error
+				subcmd, err = findAbbreviatedSubcommand(curCmd.subcommands, arg)
+				if err != nil {
+					return abort(err)
+				}
+				if subcmd != nil {
+					arg = subcmd.name
+				}
+			}
 			if subcmd == nil {
-				return fmt.Errorf("invalid subcommand: %s", arg)
+				return abort(&InvalidSubcommandError{Name: arg, Subcommand: p.subcommandPath()})
 			}
 
 			// instantiate the field to point to a new struct
@@ -698,19 +1117,35 @@ func (p *Parser) process(args []string) error {
 				specs = append(specs, subcmd.specs...)
 			}
 
-			// capture environment vars for these new options
-			if !p.config.IgnoreEnv {
-				err := p.captureEnvVars(subcmd.specs, wasPresent)
-				if err != nil {
-					return err
-				}
-			}
-
 			curCmd = subcmd
+			cmds = append(cmds, subcmd)
 			p.subcommand = append(p.subcommand, arg)
 			continue
 		}
 
+		// the origin of an option records the argument as it was typed, before any
+		// abbreviation is expanded
+		typed := arg
+
+		// expand unambiguous abbreviations such as "--verb" to "--verbose"
+		if p.config.AllowAbbreviations && strings.HasPrefix(arg, "--") {
+			builtins := []string{"help"}
+			if !hasVersionOption && p.version != "" {
+				builtins = append(builtins, "version")
+			}
+			if hasCompletionFlag {
+				builtins = append(builtins, "completion")
+			}
+			expanded, err := expandAbbreviation(specs, builtins, arg)
+			if err != nil {
+				if err := fail(err); err != nil {
+					return err
+				}
+				continue
+			}
+			arg = expanded
+		}
+
 		// check for special --help and --version flags
 		switch arg {
 		case "-h", "--help":
@@ -721,6 +1156,22 @@ func (p *Parser) process(args []string) error {
 			}
 		}
 
+		// check for the special --completion flag, which takes the name of a shell
+		if hasCompletionFlag && (arg == "--completion" || strings.HasPrefix(arg, "--completion=")) {
+			shell := strings.TrimPrefix(strings.TrimPrefix(arg, "--completion"), "=")
+			if arg == "--completion" && i+1 < len(args) && !isFlag(args[i+1]) {
+				shell = args[i+1]
+			}
+			if shell == "" {
+				return abort(&MissingValueError{Option: Option{Long: "completion"}, Arg: arg, Subcommand: p.subcommandPath()})
+			}
+			if err := checkCompletionShell(shell); err != nil {
+				return abort(err)
+			}
+			p.completionShell = shell
+			return ErrCompletion
+		}
+
 		// check for an equals sign, as in "--foo=bar"
 		var value string
 		opt := strings.TrimLeft(arg, "-")
@@ -732,10 +1183,87 @@ func (p *Parser) process(args []string) error {
 		// lookup the spec for this option (note that the "specs" slice changes as
 		// we expand subcommands so it is better not to use a map)
 		spec := findOption(specs, opt)
+
+		// if there is no exact match then try to interpret something like "-vx" or
+		// "-n5" as a cluster of short options, the last of which may have a value
+		if spec == nil && !strings.HasPrefix(arg, "--") {
+			if cluster, attached := findShortCluster(specs, arg[1:]); cluster != nil {
+				for _, flag := range cluster[:len(cluster)-1] {
+					if flag.counter {
+						count(flag, i, typed)
+						continue
+					}
+					if err := scalar.ParseValue(p.val(flag.dest), "true"); err != nil {
+						if err := fail(p.valueError(flag, arg, "true", err)); err != nil {
+							return err
+						}
+					}
+					present(flag, i, typed, "")
+				}
+				spec = cluster[len(cluster)-1]
+				value = attached
+			}
+		}
+
+		// check for the --no-<name> form of a negatable boolean
+		if spec == nil {
+			if spec = findNegatedOption(specs, opt); spec != nil {
+				present(spec, i, typed, value)
+				if value != "" {
+					if err := fail(p.valueError(spec, arg, value, errors.New("option does not take a value"))); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := scalar.ParseValue(p.val(spec.dest), "false"); err != nil {
+					if err := fail(p.valueError(spec, arg, "false", err)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+
 		if spec == nil || opt == "" {
-			return fmt.Errorf("unknown argument %s", arg)
+			if err := fail(&UnknownArgumentError{Arg: arg, Subcommand: p.subcommandPath()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// counters are incremented each time they appear without a value
+		if spec.counter && value == "" {
+			count(spec, i, typed)
+			continue
+		}
+		prior := p.origins[spec]
+		present(spec, i, typed, value)
+		delete(increments, spec)
+		index := i
+
+		// deal with options that take a fixed number of values, as in "--point 1 2"
+		if spec.maxValues > 0 {
+			// a value attached with "=" is the only value, as in "--point=1"
+			var values []string
+			if value != "" {
+				values = append(values, value)
+			}
+			for value == "" && len(values) < spec.maxValues && i+1 < len(args) && isValue(args[i+1], spec.field.Type, specs) && args[i+1] != "--" {
+				values = append(values, args[i+1])
+				i++
+			}
+			present(spec, index, typed, strings.Join(values, " "))
+			var err error
+			if len(values) < spec.minValues {
+				err = p.countError(spec, arg, spec.minValues, spec.maxValues, len(values))
+			} else if err = p.setMultiple(spec, values, true); err != nil {
+				err = p.valueError(spec, arg, strings.Join(values, " "), err)
+			}
+			if err := fail(err); err != nil {
+				return err
+			}
+			continue
 		}
-		wasPresent[spec] = true
 
 		// deal with the case of multiple values
 		if spec.cardinality == multiple {
@@ -751,9 +1279,16 @@ func (p *Parser) process(args []string) error {
 			} else {
 				values = append(values, value)
 			}
-			err := setSliceOrMap(p.val(spec.dest), values, !spec.separate)
+			joined := strings.Join(values, " ")
+			if spec.separate && prior.Kind == OriginCommandLine {
+				joined = strings.TrimSpace(prior.Value + " " + joined)
+			}
+			present(spec, index, typed, joined)
+			err := p.setMultiple(spec, values, !spec.separate)
 			if err != nil {
-				return fmt.Errorf("error processing %s: %v", arg, err)
+				if err := fail(p.valueError(spec, arg, strings.Join(values, " "), err)); err != nil {
+					return err
+				}
 			}
 			continue
 		}
@@ -764,21 +1299,35 @@ func (p *Parser) process(args []string) error {
 			value = "true"
 		}
 
+		// if the value is optional then "--foo" means the implicit value, and we
+		// never consume the next argument
+		if spec.optionalValue && value == "" {
+			if err := p.setScalar(spec, spec.implicit); err != nil {
+				if err := fail(p.valueError(spec, arg, spec.implicit, err)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
 		// if we have something like "--foo" then the value is the next argument
 		if value == "" {
-			if i+1 == len(args) {
-				return fmt.Errorf("missing value for %s", arg)
-			}
-			if !isValue(args[i+1], spec.field.Type, specs) {
-				return fmt.Errorf("missing value for %s", arg)
+			if i+1 == len(args) || !isValue(args[i+1], spec.field.Type, specs) {
+				if err := fail(&MissingValueError{Option: optionOf(spec), Arg: arg, Subcommand: p.subcommandPath()}); err != nil {
+					return err
+				}
+				continue
 			}
 			value = args[i+1]
 			i++
+			present(spec, index, typed, value)
 		}
 
-		err := scalar.ParseValue(p.val(spec.dest), value)
+		err := p.setScalar(spec, value)
 		if err != nil {
-			return fmt.Errorf("error processing %s: %v", arg, err)
+			if err := fail(p.valueError(spec, arg, value, err)); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -790,56 +1339,183 @@ func (p *Parser) process(args []string) error {
 		if len(positionals) == 0 {
 			break
 		}
-		wasPresent[spec] = true
 		if spec.cardinality == multiple {
-			err := setSliceOrMap(p.val(spec.dest), positionals, true)
+			present(spec, positionalIndices[0], "", strings.Join(positionals, " "))
+			err := p.setMultiple(spec, positionals, true)
 			if err != nil {
-				return fmt.Errorf("error processing %s: %v", spec.placeholder, err)
+				if err := fail(p.valueError(spec, "", strings.Join(positionals, " "), err)); err != nil {
+					return err
+				}
 			}
 			positionals = nil
 		} else {
-			err := scalar.ParseValue(p.val(spec.dest), positionals[0])
+			present(spec, positionalIndices[0], "", positionals[0])
+			err := p.setScalar(spec, positionals[0])
 			if err != nil {
-				return fmt.Errorf("error processing %s: %v", spec.placeholder, err)
+				if err := fail(p.valueError(spec, "", positionals[0], err)); err != nil {
+					return err
+				}
 			}
 			positionals = positionals[1:]
+			positionalIndices = positionalIndices[1:]
 		}
 	}
 	if len(positionals) > 0 {
-		return fmt.Errorf("too many positional arguments at '%s'", positionals[0])
+		if err := fail(&TooManyPositionalsError{Arg: positionals[0], Subcommand: p.subcommandPath()}); err != nil {
+			return err
+		}
 	}
 
-	// fill in defaults and check that all the required args were provided
-	for _, spec := range specs {
-		if wasPresent[spec] {
-			continue
-		}
+	// fill in everything not given on the command line from the environment, config
+	// files, defaults, and other sources, and check that all the required args were
+	// provided
+	if err := fail(p.captureSources(cmds, specs, wasPresent, increments)); err != nil {
+		return err
+	}
 
-		if spec.required {
-			if spec.short == "" && spec.long == "" {
-				msg := fmt.Sprintf("environment variable %s is required", spec.env)
-				return errors.New(msg)
-			}
+	// during completion the values are only needed by completers, so none of the
+	// checks below are made and no Validate methods are called
+	if p.completing {
+		return nil
+	}
 
-			msg := fmt.Sprintf("%s is required", spec.placeholder)
-			if spec.env != "" {
-				msg += " (or environment variable " + spec.env + ")"
-			}
+	// check that the options in each group were used together correctly
+	if err := fail(p.checkGroups(cmds, specs, wasPresent)); err != nil {
+		return err
+	}
+
+	// check the requires, required-if, and required-unless relationships
+	if err := fail(p.checkDependencies(specs, wasPresent)); err != nil {
+		return err
+	}
 
-			return errors.New(msg)
+	// check the rules from validate tags now that defaults have been filled in
+	if err := fail(p.checkValidation(specs)); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// finally let the structs validate themselves
+	return p.callValidators(cmds)
+}
+
+// parseNargs parses an nargs tag of the form "N" or "N..M" into the minimum and
+// maximum number of values
+func parseNargs(s string) (int, int, error) {
+	lo, hi := s, s
+	if pos := strings.Index(s, ".."); pos != -1 {
+		lo, hi = s[:pos], s[pos+2:]
+	}
+	min, err1 := strconv.Atoi(strings.TrimSpace(lo))
+	max, err2 := strconv.Atoi(strings.TrimSpace(hi))
+	if err1 != nil || err2 != nil || min < 1 || max < min {
+		return 0, 0, fmt.Errorf("invalid nargs %q, expected a number such as \"2\" or a range such as \"2..3\"", s)
+	}
+	return min, max, nil
+}
+
+// arity describes the number of values taken by a fixed-arity option, such as "2 values"
+func arity(min, max int) string {
+	switch {
+	case min != max:
+		return fmt.Sprintf("%d to %d values", min, max)
+	case min == 1:
+		return "1 value"
+	default:
+		return fmt.Sprintf("%d values", min)
+	}
+}
+
+// countError constructs the error for an option that was given got values when it
+// takes between min and max of them. The arg describes how the option was
+// provided, as in MissingValueError.
+func (p *Parser) countError(spec *spec, arg string, min, max, got int) error {
+	if got < min {
+		return &MissingValueError{
+			Option:     optionOf(spec),
+			Arg:        arg,
+			MinValues:  min,
+			MaxValues:  max,
+			Got:        got,
+			Subcommand: p.subcommandPath(),
 		}
+	}
+	return &TooManyValuesError{
+		Option:     optionOf(spec),
+		Arg:        arg,
+		MinValues:  min,
+		MaxValues:  max,
+		Got:        got,
+		Subcommand: p.subcommandPath(),
+	}
+}
 
+// incrementCounter adds one to the integer field for a counter option. If the
+// option has not yet been seen then counting starts from its default value.
+func (p *Parser) incrementCounter(spec *spec, started bool) {
+	v := p.val(spec.dest)
+	if !started {
+		start := reflect.New(v.Type()).Elem()
 		if spec.defaultValue.IsValid() && !p.config.IgnoreDefault {
-			// One issue here is that if the user now modifies the value then
-			// the default value stored in the spec will be corrupted. There
-			// is no general way to "deep-copy" values in Go, and we still
-			// support the old-style method for specifying defaults as
-			// Go values assigned directly to the struct field, so we are stuck.
-			p.val(spec.dest).Set(spec.defaultValue)
+			start.Set(spec.defaultValue)
 		}
+		if v.Kind() == reflect.Ptr {
+			// copy the pointed-to value so that the default is not modified below
+			ptr := reflect.New(v.Type().Elem())
+			if !start.IsNil() {
+				ptr.Elem().Set(start.Elem())
+			}
+			start = ptr
+		}
+		v.Set(start)
 	}
 
-	return nil
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	default:
+		v.SetUint(v.Uint() + 1)
+	}
+}
+
+// valueError constructs the error for a value from the command line that could
+// not be parsed into the field for the given spec
+func (p *Parser) valueError(spec *spec, arg, value string, err error) error {
+	return &ValueParseError{
+		Option:     optionOf(spec),
+		Origin:     OriginCommandLine,
+		Arg:        arg,
+		Value:      value,
+		Subcommand: p.subcommandPath(),
+		Err:        err,
+	}
+}
+
+// envError constructs the error for a value from the environment variable of the
+// given spec that could not be parsed into its field
+func (p *Parser) envError(spec *spec, value string, err error) error {
+	return &ValueParseError{
+		Option:     optionOf(spec),
+		Origin:     OriginEnv,
+		Value:      value,
+		Subcommand: p.subcommandPath(),
+		Err:        err,
+	}
+}
+
+// subcommandPath returns a copy of the subcommands selected so far
+func (p *Parser) subcommandPath() []string {
+	return append([]string{}, p.subcommand...)
 }
 
 // isFlag returns true if a token is a flag such as "-v" or "--user" but not "-" or "--"
@@ -852,13 +1528,13 @@ func isFlag(s string) bool {
 // case we check the list of active options and return true if its not present there.
 func isValue(s string, t reflect.Type, specs []*spec) bool {
 	switch t.Kind() {
-	case reflect.Ptr, reflect.Slice:
+	case reflect.Ptr, reflect.Slice, reflect.Array:
 		return isValue(s, t.Elem(), specs)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
 		v := reflect.New(t)
 		err := scalar.ParseValue(v, s)
 		// if value can be parsed and is not an explicit option declared elsewhere, then use it as a value
-		if err == nil && (!strings.HasPrefix(s, "-") || findOption(specs, strings.TrimPrefix(s, "-")) == nil) {
+		if err == nil && (!strings.HasPrefix(s, "-") || !isOption(specs, strings.TrimPrefix(s, "-"))) {
 			return true
 		}
 	}
@@ -897,6 +1573,102 @@ func findOption(specs []*spec, name string) *spec {
 	return nil
 }
 
+// findNegatedOption finds a negatable option from a name of the form "no-<long>",
+// or returns nil if there is no such option
+func findNegatedOption(specs []*spec, name string) *spec {
+	if !strings.HasPrefix(name, "no-") {
+		return nil
+	}
+	for _, spec := range specs {
+		if spec.negatable && spec.long == name[3:] {
+			return spec
+		}
+	}
+	return nil
+}
+
+// expandAbbreviation replaces a unique prefix of a long option name in a token
+// such as "--verb" or "--verb=x" with the full option name. The token is returned
+// unchanged if it is an exact match or if no option matches. An error is returned
+// if the prefix matches more than one option.
+func expandAbbreviation(specs []*spec, builtins []string, arg string) (string, error) {
+	name := arg[2:]
+	var rest string
+	if pos := strings.Index(name, "="); pos != -1 {
+		rest = name[pos:]
+		name = name[:pos]
+	}
+	if name == "" {
+		return arg, nil
+	}
+
+	// make a list of all the long names that are currently active
+	names := append([]string{}, builtins...)
+	for _, spec := range specs {
+		if spec.positional || spec.long == "" {
+			continue
+		}
+		names = append(names, spec.long)
+		if spec.negatable {
+			names = append(names, "no-"+spec.long)
+		}
+	}
+
+	var matches []string
+	for _, candidate := range names {
+		if candidate == name {
+			return arg, nil
+		}
+		if strings.HasPrefix(candidate, name) && !contains(matches, "--"+candidate) {
+			matches = append(matches, "--"+candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return arg, nil
+	case 1:
+		return matches[0] + rest, nil
+	default:
+		return "", fmt.Errorf("ambiguous argument %s (could be %s)", arg, strings.Join(matches, ", "))
+	}
+}
+
+// findShortCluster interprets a token such as "vx" or "n5" (without its leading
+// hyphen) as a sequence of short options. All but the last option must be
+// booleans. If the last option takes a value then the remainder of the token
+// is returned as its value, or the empty string if the value is to be taken
+// from the next argument. If the last option is a boolean then it may be
+// followed by "=value", as in "vx=false". It returns nil if the token is not a
+// valid cluster.
+func findShortCluster(specs []*spec, name string) ([]*spec, string) {
+	var cluster []*spec
+	for i, r := range name {
+		if r == '=' && len(cluster) > 0 {
+			return cluster, name[i+1:]
+		}
+		spec := findOption(specs, string(r))
+		if spec == nil {
+			return nil, ""
+		}
+		cluster = append(cluster, spec)
+		if spec.cardinality != zero {
+			return cluster, strings.TrimPrefix(name[i+utf8.RuneLen(r):], "=")
+		}
+	}
+	return cluster, ""
+}
+
+// isOption returns true if name (without its leading hyphens) refers to an option
+// in specs, either directly or as a cluster of short options
+func isOption(specs []*spec, name string) bool {
+	if findOption(specs, name) != nil {
+		return true
+	}
+	cluster, _ := findShortCluster(specs, name)
+	return cluster != nil
+}
+
 // findSubcommand finds a subcommand using its name, or returns null if no subcommand is found
 func findSubcommand(cmds []*command, name string) *command {
 	for _, cmd := range cmds {
@@ -911,3 +1683,39 @@ func findSubcommand(cmds []*command, name string) *command {
 	}
 	return nil
 }
+
+// findAbbreviatedSubcommand finds the subcommand with a name or alias that starts
+// with the given prefix, or returns nil if there is no such subcommand. It returns
+// an error if the prefix matches more than one subcommand.
+func findAbbreviatedSubcommand(cmds []*command, prefix string) (*command, error) {
+	var found []*command
+	var names []string
+	for _, cmd := range cmds {
+		for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+			if strings.HasPrefix(name, prefix) {
+				found = append(found, cmd)
+				names = append(names, cmd.name)
+				break
+			}
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, nil
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("ambiguous subcommand %s (could be %s)", prefix, strings.Join(names, ", "))
+	}
+}
+
+// contains returns true if the string s is in the list
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
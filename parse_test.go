@@ -3,6 +3,7 @@ package arg
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/mail"
@@ -350,6 +351,420 @@ func TestLongFlag(t *testing.T) {
 	assert.Equal(t, "xyz", args.Foo)
 }
 
+func TestShortFlagCluster(t *testing.T) {
+	var args struct {
+		A bool `arg:"-a"`
+		B bool `arg:"-b"`
+		C bool `arg:"-c"`
+	}
+	err := parse("-ac", &args)
+	require.NoError(t, err)
+	assert.True(t, args.A)
+	assert.False(t, args.B)
+	assert.True(t, args.C)
+}
+
+func TestShortFlagClusterWithAttachedValue(t *testing.T) {
+	var args struct {
+		Verbose bool   `arg:"-v"`
+		Output  string `arg:"-o"`
+		N       int    `arg:"-n"`
+	}
+	err := parse("-vofile -n5", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	assert.Equal(t, "file", args.Output)
+	assert.Equal(t, 5, args.N)
+}
+
+func TestShortFlagClusterWithNextValue(t *testing.T) {
+	var args struct {
+		Verbose bool   `arg:"-v"`
+		Output  string `arg:"-o"`
+	}
+	err := parse("-vo file", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	assert.Equal(t, "file", args.Output)
+}
+
+func TestShortFlagClusterWithSlice(t *testing.T) {
+	var args struct {
+		Verbose bool     `arg:"-v"`
+		Inputs  []string `arg:"-i"`
+	}
+	err := parse("-vi a b", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	assert.Equal(t, []string{"a", "b"}, args.Inputs)
+}
+
+func TestShortFlagClusterWithNegativeValue(t *testing.T) {
+	var args struct {
+		N int `arg:"-n"`
+	}
+	err := parse("-n-5", &args)
+	require.NoError(t, err)
+	assert.Equal(t, -5, args.N)
+}
+
+func TestShortFlagClusterWithBooleanValue(t *testing.T) {
+	var args struct {
+		Verbose bool `arg:"-v"`
+		Force   bool `arg:"-f"`
+	}
+	args.Force = true
+	err := parse("-vf=false", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	assert.False(t, args.Force)
+
+	err = parse("-f=false", &args)
+	require.NoError(t, err)
+	assert.False(t, args.Force)
+
+	err = parse("-vf=nope", &args)
+	assert.EqualError(t, err, "error processing -vf=nope: strconv.ParseBool: parsing \"nope\": invalid syntax")
+}
+
+func TestShortFlagClusterUnknown(t *testing.T) {
+	var args struct {
+		Verbose bool `arg:"-v"`
+	}
+	err := parse("-vx", &args)
+	assert.EqualError(t, err, "unknown argument -vx")
+}
+
+func TestShortFlagClusterMissingValue(t *testing.T) {
+	var args struct {
+		Verbose bool   `arg:"-v"`
+		Output  string `arg:"-o"`
+	}
+	err := parse("-vo", &args)
+	assert.EqualError(t, err, "missing value for -vo")
+}
+
+func TestShortFlagClusterTakesPrecedenceOverNegativeNumber(t *testing.T) {
+	var args struct {
+		One bool `arg:"-1"`
+		Two bool `arg:"-2"`
+		Foo int
+	}
+	err := parse("--foo -12", &args)
+	require.Error(t, err)
+
+	err = parse("--foo -13", &args)
+	require.NoError(t, err)
+	assert.Equal(t, -13, args.Foo)
+}
+
+func TestCounter(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter"`
+	}
+	err := parse("-v -v", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 2, args.Verbose)
+
+	args.Verbose = 0
+	err = parse("-vvv", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 3, args.Verbose)
+
+	args.Verbose = 0
+	err = parse("--verbose --verbose", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 2, args.Verbose)
+}
+
+func TestCounterStartsFromInitialValue(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter"`
+	}
+	args.Verbose = 2
+	err := parse("-v", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 3, args.Verbose)
+}
+
+func TestCounterWithExplicitValue(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter"`
+	}
+	err := parse("--verbose=5", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 5, args.Verbose)
+}
+
+func TestCounterInCluster(t *testing.T) {
+	var args struct {
+		Verbose uint8  `arg:"-v,counter"`
+		Output  string `arg:"-o"`
+	}
+	err := parse("-vvofile", &args)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, args.Verbose)
+	assert.Equal(t, "file", args.Output)
+}
+
+func TestCounterPointer(t *testing.T) {
+	var args struct {
+		Verbose *int `arg:"-v,counter"`
+	}
+	err := parse("-vv", &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Verbose)
+	assert.Equal(t, 2, *args.Verbose)
+}
+
+func TestCounterWithDefault(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter" default:"2"`
+	}
+	err := parse("-v", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 3, args.Verbose)
+}
+
+func TestCounterNotPresent(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter" default:"2"`
+	}
+	err := parse("", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 2, args.Verbose)
+}
+
+func TestCounterWithEnv(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter,env" default:"2"`
+	}
+	_, err := parseWithEnv(Config{}, "-vv", []string{"VERBOSE=5"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, 7, args.Verbose)
+}
+
+func TestCounterNotInteger(t *testing.T) {
+	var args struct {
+		Verbose string `arg:"-v,counter"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, ".Verbose: counters must be integer fields but this field is string")
+}
+
+func TestCounterNotPositional(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"positional,counter"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
+func TestNegatable(t *testing.T) {
+	var args struct {
+		Color bool `arg:"negatable" default:"true"`
+	}
+	err := parse("--no-color", &args)
+	require.NoError(t, err)
+	assert.False(t, args.Color)
+
+	err = parse("--color", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Color)
+}
+
+func TestNegatablePointer(t *testing.T) {
+	var args struct {
+		Color *bool `arg:"negatable"`
+	}
+	err := parse("--no-color", &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Color)
+	assert.False(t, *args.Color)
+}
+
+func TestNegatableNotEnabled(t *testing.T) {
+	var args struct {
+		Color bool
+	}
+	err := parse("--no-color", &args)
+	assert.EqualError(t, err, "unknown argument --no-color")
+}
+
+func TestNegatableFlagsConfig(t *testing.T) {
+	var args struct {
+		Color   bool `default:"true"`
+		Verbose bool `arg:"-v"`
+	}
+	_, err := parseWithEnv(Config{NegatableFlags: true}, "--no-color --no-verbose", nil, &args)
+	require.NoError(t, err)
+	assert.False(t, args.Color)
+	assert.False(t, args.Verbose)
+}
+
+func TestNegatableWithValue(t *testing.T) {
+	var args struct {
+		Color bool `arg:"negatable"`
+	}
+	err := parse("--no-color=true", &args)
+	assert.EqualError(t, err, "error processing --no-color=true: option does not take a value")
+
+	var perr *ValueParseError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, "color", perr.Long)
+	assert.Equal(t, "--no-color=true", perr.Arg)
+	assert.Equal(t, "true", perr.Value)
+
+	_, err = parseWithEnv(Config{CollectErrors: true}, "--no-color=true --nope", nil, &args)
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	assert.True(t, errors.As(errs[0], &perr))
+}
+
+func TestNegatableNotBoolean(t *testing.T) {
+	var args struct {
+		Color string `arg:"negatable"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
+func TestNegatableConflict(t *testing.T) {
+	var args struct {
+		Color   bool `arg:"negatable"`
+		NoColor bool `arg:"--no-color"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, "args.Color: --no-color conflicts with the option for args.NoColor")
+}
+
+func TestAbbreviation(t *testing.T) {
+	var args struct {
+		Verbose bool
+		Output  string
+	}
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "--verb --out=x", nil, &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	assert.Equal(t, "x", args.Output)
+}
+
+func TestAbbreviationNotEnabled(t *testing.T) {
+	var args struct {
+		Verbose bool
+	}
+	err := parse("--verb", &args)
+	assert.EqualError(t, err, "unknown argument --verb")
+}
+
+func TestAbbreviationAmbiguous(t *testing.T) {
+	var args struct {
+		Verbose  bool
+		Verbatim bool
+	}
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "--verb", nil, &args)
+	assert.EqualError(t, err, "ambiguous argument --verb (could be --verbose, --verbatim)")
+}
+
+func TestAbbreviationExactMatchWins(t *testing.T) {
+	var args struct {
+		Verb    bool
+		Verbose bool
+	}
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "--verb", nil, &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verb)
+	assert.False(t, args.Verbose)
+}
+
+func TestAbbreviationOfNegatable(t *testing.T) {
+	var args struct {
+		Color bool `arg:"negatable" default:"true"`
+	}
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "--no-col", nil, &args)
+	require.NoError(t, err)
+	assert.False(t, args.Color)
+}
+
+func TestAbbreviationOfHelp(t *testing.T) {
+	var args struct {
+		Verbose bool
+	}
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "--he", nil, &args)
+	assert.Equal(t, ErrHelp, err)
+}
+
+func TestOptionalValue(t *testing.T) {
+	var args struct {
+		Color string `implicit:"auto" default:"never"`
+		Input string `arg:"positional"`
+	}
+	err := parse("--color x", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "auto", args.Color)
+	assert.Equal(t, "x", args.Input)
+}
+
+func TestOptionalValueWithEquals(t *testing.T) {
+	var args struct {
+		Color string `implicit:"auto" default:"never"`
+	}
+	err := parse("--color=always", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "always", args.Color)
+}
+
+func TestOptionalValueNotPresent(t *testing.T) {
+	var args struct {
+		Color string `implicit:"auto" default:"never"`
+	}
+	err := parse("", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "never", args.Color)
+}
+
+func TestOptionalValueShort(t *testing.T) {
+	var args struct {
+		Verbose bool `arg:"-v"`
+		Level   int  `arg:"-l" implicit:"1"`
+	}
+	err := parse("-vl", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	assert.Equal(t, 1, args.Level)
+
+	err = parse("-vl3", &args)
+	require.NoError(t, err)
+	assert.Equal(t, 3, args.Level)
+}
+
+func TestOptionalValueTag(t *testing.T) {
+	var args struct {
+		Name string `arg:"optional-value"`
+	}
+	args.Name = "x"
+	err := parse("--name", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "", args.Name)
+}
+
+func TestOptionalValueInvalidImplicit(t *testing.T) {
+	var args struct {
+		Level int `implicit:"high"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
+func TestOptionalValueNotSupportedForSlice(t *testing.T) {
+	var args struct {
+		Levels []int `implicit:"1"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
 func TestSlice(t *testing.T) {
 	var args struct {
 		Strings []string
@@ -368,6 +783,107 @@ func TestSliceOfBools(t *testing.T) {
 	assert.Equal(t, []bool{true, false, true}, args.B)
 }
 
+func TestArray(t *testing.T) {
+	var args struct {
+		Point [2]float64
+		Rest  []string `arg:"positional"`
+	}
+	err := parse("--point 1.5 -2 x", &args)
+	require.NoError(t, err)
+	assert.Equal(t, [2]float64{1.5, -2}, args.Point)
+	assert.Equal(t, []string{"x"}, args.Rest)
+}
+
+func TestArrayTooFewValues(t *testing.T) {
+	var args struct {
+		Point [2]float64
+		Other bool
+	}
+	err := parse("--point 1 --other", &args)
+	assert.EqualError(t, err, "--point requires 2 values but got 1")
+}
+
+func TestArrayPointer(t *testing.T) {
+	var args struct {
+		Point *[3]int
+	}
+	err := parse("--point 1 2 3", &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Point)
+	assert.Equal(t, [3]int{1, 2, 3}, *args.Point)
+}
+
+func TestNargs(t *testing.T) {
+	var args struct {
+		Resize []int    `nargs:"2..3"`
+		Rest   []string `arg:"positional"`
+	}
+	err := parse("--resize 1 2 3 4", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, args.Resize)
+	assert.Equal(t, []string{"4"}, args.Rest)
+
+	err = parse("--resize 1", &args)
+	assert.EqualError(t, err, "--resize requires 2 to 3 values but got 1")
+}
+
+func TestNargsWithEquals(t *testing.T) {
+	var args struct {
+		Size []int    `nargs:"1..2"`
+		Rest []string `arg:"positional"`
+	}
+	err := parse("--size=1 2", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, args.Size)
+	assert.Equal(t, []string{"2"}, args.Rest)
+}
+
+func TestNargsWithEqualsTooFewValues(t *testing.T) {
+	var args struct {
+		Size  []int    `nargs:"2"`
+		Point [2]int   `arg:"-p"`
+		Rest  []string `arg:"positional"`
+	}
+	err := parse("--size=1 2", &args)
+	assert.EqualError(t, err, "--size=1 requires 2 values but got 1")
+
+	err = parse("-p=1 2", &args)
+	assert.EqualError(t, err, "-p=1 requires 2 values but got 1")
+}
+
+func TestNargsReplacesPreviousValues(t *testing.T) {
+	var args struct {
+		Size []int `nargs:"2"`
+	}
+	err := parse("--size 1 2 --size 3 4", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, args.Size)
+}
+
+func TestNargsInvalid(t *testing.T) {
+	var args struct {
+		Size []int `nargs:"3..2"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
+func TestNargsNotSlice(t *testing.T) {
+	var args struct {
+		Size int `nargs:"2"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, ".Size: nargs is only supported for slice fields")
+}
+
+func TestArrayPositionalNotSupported(t *testing.T) {
+	var args struct {
+		Point [2]int `arg:"positional"`
+	}
+	err := parse("", &args)
+	assert.Error(t, err)
+}
+
 func TestMap(t *testing.T) {
 	var args struct {
 		Values map[string]int
@@ -942,6 +1458,18 @@ func TestEnvironmentVariableSliceArgumentWrongType(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestEnvironmentVariableArray(t *testing.T) {
+	var args struct {
+		Point [2]int `arg:"env"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"POINT=1,2"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{1, 2}, args.Point)
+
+	_, err = parseWithEnv(Config{}, "", []string{"POINT=1,2,3"}, &args)
+	assert.EqualError(t, err, "environment variable POINT requires 2 values but got 3")
+}
+
 func TestEnvironmentVariableMap(t *testing.T) {
 	var args struct {
 		Foo map[int]string `arg:"env"`
@@ -1062,7 +1590,7 @@ func TestParserMustParse(t *testing.T) {
 			var stdout bytes.Buffer
 			exit := func(code int) { exitCode = code }
 
-			p, err := NewParser(Config{Exit: exit, Out: &stdout}, &tt.args)
+			p, err := NewParser(Config{Exit: exit, Out: &stdout, HelpWidth: 80}, &tt.args)
 			require.NoError(t, err)
 			assert.NotNil(t, p)
 
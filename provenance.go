@@ -0,0 +1,124 @@
+package arg
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OriginKind identifies where the value of an option came from
+type OriginKind int
+
+const (
+	OriginUnset        OriginKind = iota // the option was not set by the parser
+	OriginCommandLine                    // the option was given on the command line
+	OriginEnv                            // the value came from an environment variable
+	OriginConfigFile                     // the value came from a config file
+	OriginSource                         // the value came from a source in Config.Sources
+	OriginDefault                        // the value came from a default tag
+	OriginInitialValue                   // the value is the initial value of the field
+)
+
+func (k OriginKind) String() string {
+	switch k {
+	case OriginUnset:
+		return "unset"
+	case OriginCommandLine:
+		return "command line"
+	case OriginEnv:
+		return "environment variable"
+	case OriginConfigFile:
+		return "config file"
+	case OriginSource:
+		return "source"
+	case OriginDefault:
+		return "default"
+	case OriginInitialValue:
+		return "initial value"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(k))
+	}
+}
+
+// Origin describes where the value of an option came from during the most recent
+// call to Parse
+type Origin struct {
+	Kind   OriginKind
+	Value  string // the text from which the value was parsed, with multiple values separated by spaces, or empty if an option appeared without a value
+	Arg    string // for OriginCommandLine, the argument as it appeared on the command line, such as "--port" or "-vx"
+	Index  int    // for OriginCommandLine, the index of the argument in the list passed to Parse after expanding response files, otherwise -1
+	Env    string // for OriginEnv, the name of the environment variable
+	File   string // for OriginConfigFile, the path to the config file
+	Key    string // for OriginConfigFile, the key containing the value, with subcommand sections separated by periods
+	Source Source // for OriginSource, the source that supplied the value
+}
+
+// Provenance gets the origin of the value of an option after parsing. The option
+// is identified either by a pointer to its field, as in p.Provenance(&args.Port),
+// or by its long name, short name, or the name of a positional argument. Names
+// are looked up in the same way as on the command line, in the top-level command
+// and then in each of the selected subcommands. It panics if there is no such
+// option.
+func (p *Parser) Provenance(option interface{}) Origin {
+	spec := p.lookupSpec(option)
+	if origin, ok := p.origins[spec]; ok {
+		return origin
+	}
+	return Origin{Kind: OriginUnset, Index: -1}
+}
+
+// IsSet returns true if an option was provided on the command line, through an
+// environment variable, in a config file, or by a source during the most recent
+// call to Parse, and false if it took its default value or kept its initial value.
+// The option is identified in the same way as for Provenance.
+func (p *Parser) IsSet(option interface{}) bool {
+	switch p.Provenance(option).Kind {
+	case OriginUnset, OriginDefault, OriginInitialValue:
+		return false
+	default:
+		return true
+	}
+}
+
+// lookupSpec finds the spec for an option given by a pointer to its field or by name
+func (p *Parser) lookupSpec(option interface{}) *spec {
+	if name, ok := option.(string); ok {
+		cmds := []*command{p.cmd}
+		for _, name := range p.subcommand {
+			cmds = append(cmds, findSubcommand(cmds[len(cmds)-1].subcommands, name))
+		}
+		for _, cmd := range cmds {
+			for _, spec := range cmd.specs {
+				if spec.long == name || spec.short == name {
+					return spec
+				}
+			}
+		}
+		panic(fmt.Sprintf("there is no option named %q", name))
+	}
+
+	ptr := reflect.ValueOf(option)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		panic(fmt.Sprintf("%T is not a pointer to a field or the name of an option", option))
+	}
+	if spec := p.findSpecByAddr(p.cmd, ptr); spec != nil {
+		return spec
+	}
+	panic(fmt.Sprintf("%T does not point to a field for an option", option))
+}
+
+// findSpecByAddr finds the spec whose field is at the address given by a pointer,
+// searching through a command and its subcommands
+func (p *Parser) findSpecByAddr(cmd *command, ptr reflect.Value) *spec {
+	for _, spec := range cmd.specs {
+		v := p.val(spec.dest)
+		if v.IsValid() && v.Type() == ptr.Type().Elem() && v.Addr().Pointer() == ptr.Pointer() {
+			return spec
+		}
+	}
+	for _, subcmd := range cmd.subcommands {
+		if spec := p.findSpecByAddr(subcmd, ptr); spec != nil {
+			return spec
+		}
+	}
+	return nil
+}
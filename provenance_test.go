@@ -0,0 +1,160 @@
+package arg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	var args struct {
+		Port    int    `arg:"-p"`
+		Host    string `arg:"env:PROVENANCE_HOST"`
+		Timeout int    `default:"30"`
+		Retries int
+		User    string
+		Verbose bool   `arg:"-v"`
+		Input   string `arg:"positional"`
+	}
+	args.Retries = 3
+
+	p, err := parseWithEnv(Config{}, "-v in.txt -p 8080", []string{"PROVENANCE_HOST=example.com"}, &args)
+	require.NoError(t, err)
+
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Value: "8080", Arg: "-p", Index: 2}, p.Provenance(&args.Port))
+	assert.Equal(t, Origin{Kind: OriginEnv, Value: "example.com", Index: -1, Env: "PROVENANCE_HOST"}, p.Provenance(&args.Host))
+	assert.Equal(t, Origin{Kind: OriginDefault, Value: "30", Index: -1}, p.Provenance(&args.Timeout))
+	assert.Equal(t, Origin{Kind: OriginInitialValue, Value: "3", Index: -1}, p.Provenance(&args.Retries))
+	assert.Equal(t, Origin{Kind: OriginUnset, Index: -1}, p.Provenance(&args.User))
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Arg: "-v", Index: 0}, p.Provenance(&args.Verbose))
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Value: "in.txt", Index: 1}, p.Provenance(&args.Input))
+}
+
+func TestProvenanceByName(t *testing.T) {
+	var args struct {
+		Port int `arg:"-p"`
+	}
+	p, err := pparse("--port=8080", &args)
+	require.NoError(t, err)
+	assert.Equal(t, OriginCommandLine, p.Provenance("port").Kind)
+	assert.Equal(t, "--port=8080", p.Provenance("p").Arg)
+	assert.Equal(t, "8080", p.Provenance("p").Value)
+}
+
+func TestProvenanceAbbreviation(t *testing.T) {
+	var args struct {
+		Port int
+	}
+	p, err := parseWithEnv(Config{AllowAbbreviations: true}, "--po 8080", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Value: "8080", Arg: "--po", Index: 0}, p.Provenance(&args.Port))
+}
+
+func TestProvenanceMultipleValues(t *testing.T) {
+	var args struct {
+		Tags []string
+		Dirs []string `arg:"separate"`
+	}
+	p, err := pparse("--dirs a --tags x y --dirs b", &args)
+	require.NoError(t, err)
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Value: "x y", Arg: "--tags", Index: 2}, p.Provenance(&args.Tags))
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Value: "a b", Arg: "--dirs", Index: 5}, p.Provenance(&args.Dirs))
+}
+
+func TestProvenanceConfigFileAndSource(t *testing.T) {
+	path := writeConfigFile(t, `{"get": {"name": "alice"}}`)
+	src := mapSource{"get.count": {"3"}}
+
+	type getCmd struct {
+		Name  string
+		Count int
+	}
+	var args struct {
+		Get *getCmd `arg:"subcommand"`
+	}
+	p, err := parseWithEnv(Config{
+		ConfigFiles: []string{path},
+		Sources:     []PrioritizedSource{{Source: src}},
+	}, "get", nil, &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Get)
+
+	assert.Equal(t, Origin{Kind: OriginConfigFile, Value: "alice", Index: -1, File: path, Key: "get.name"}, p.Provenance(&args.Get.Name))
+	assert.Equal(t, Origin{Kind: OriginSource, Value: "3", Index: -1, Source: src}, p.Provenance("count"))
+}
+
+func TestProvenanceUnknownOption(t *testing.T) {
+	var args struct {
+		Port int
+	}
+	var other int
+	p, err := pparse("", &args)
+	require.NoError(t, err)
+	assert.Panics(t, func() { p.Provenance("host") })
+	assert.Panics(t, func() { p.Provenance(&other) })
+	assert.Panics(t, func() { p.Provenance(3) })
+}
+
+func TestOriginKindString(t *testing.T) {
+	assert.Equal(t, "command line", OriginCommandLine.String())
+	assert.Equal(t, "environment variable", OriginEnv.String())
+	assert.Equal(t, "initial value", OriginInitialValue.String())
+	assert.Equal(t, "unknown(42)", OriginKind(42).String())
+}
+
+func TestIsSet(t *testing.T) {
+	var args struct {
+		Timeout int    `default:"0"`
+		Retries int    `default:"3"`
+		Host    string `arg:"env:IS_SET_HOST"`
+		User    string
+		Input   string `arg:"positional"`
+		Output  string `arg:"positional"`
+	}
+	p, err := parseWithEnv(Config{}, "--timeout 0 in.txt", []string{"IS_SET_HOST=example.com"}, &args)
+	require.NoError(t, err)
+	assert.True(t, p.IsSet(&args.Timeout))
+	assert.False(t, p.IsSet(&args.Retries))
+	assert.True(t, p.IsSet(&args.Host))
+	assert.False(t, p.IsSet("user"))
+	assert.True(t, p.IsSet("input"))
+	assert.False(t, p.IsSet(&args.Output))
+}
+
+func TestIsSetSubcommand(t *testing.T) {
+	type getCmd struct {
+		Force bool
+		Name  string
+	}
+	var args struct {
+		Verbose bool
+		Get     *getCmd `arg:"subcommand"`
+	}
+	p, err := pparse("get --force", &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Get)
+	assert.True(t, p.IsSet("force"))
+	assert.True(t, p.IsSet(&args.Get.Force))
+	assert.False(t, p.IsSet(&args.Verbose))
+	assert.False(t, p.IsSet("name"))
+}
+
+func TestProvenanceNameLookupMatchesCommandLine(t *testing.T) {
+	type subCmd struct {
+		Name string
+	}
+	var args struct {
+		Name string
+		Sub  *subCmd `arg:"subcommand"`
+	}
+	p, err := pparse("sub --name x", &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Sub)
+
+	// on the command line --name refers to the top-level option, so it does here too
+	assert.Equal(t, "x", args.Name)
+	assert.Equal(t, Origin{Kind: OriginCommandLine, Value: "x", Arg: "--name", Index: 1}, p.Provenance("name"))
+	assert.True(t, p.IsSet("name"))
+	assert.False(t, p.IsSet(&args.Sub.Name))
+}
@@ -15,7 +15,7 @@ var textUnmarshalerType = reflect.TypeOf([]encoding.TextUnmarshaler{}).Elem()
 // cardinality tracks how many tokens are expected for a given spec
 //   - zero is a boolean, which does to expect any value
 //   - one is an ordinary option that will be parsed from a single token
-//   - multiple is a slice or map that can accept zero or more tokens
+//   - multiple is a slice, array, or map that can accept zero or more tokens
 type cardinality int
 
 const (
@@ -54,9 +54,9 @@ func cardinalityOf(t reflect.Type) (cardinality, error) {
 		t = t.Elem()
 	}
 
-	// look inside slice and map types
+	// look inside slice, array, and map types
 	switch t.Kind() {
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
 		if !scalar.CanParse(t.Elem()) {
 			return unsupported, fmt.Errorf("cannot parse into %v because %v not supported", t, t.Elem())
 		}
@@ -88,6 +88,31 @@ func isBoolean(t reflect.Type) bool {
 	}
 }
 
+// isArray returns true if the type is an array or a pointer to an array
+func isArray(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Array && !isTextUnmarshaler(t)
+}
+
+// isInteger returns true if the type is a signed or unsigned integer or a pointer to one
+func isInteger(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if isTextUnmarshaler(t) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
 // isTextUnmarshaler returns true if the type or its pointer implements encoding.TextUnmarshaler
 func isTextUnmarshaler(t reflect.Type) bool {
 	return t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType)
@@ -23,6 +23,7 @@ func TestCardinalityOf(t *testing.T) {
 	var bs []bool
 	var is []int
 	var m map[string]int
+	var a [2]int
 	var unsupported1 struct{}
 	var unsupported2 []struct{}
 	var unsupported3 map[string]struct{}
@@ -47,6 +48,9 @@ func TestCardinalityOf(t *testing.T) {
 	assertCardinality(t, reflect.TypeOf(m), multiple)
 	assertCardinality(t, reflect.TypeOf(&m), multiple)
 
+	assertCardinality(t, reflect.TypeOf(a), multiple)
+	assertCardinality(t, reflect.TypeOf(&a), multiple)
+
 	assertCardinality(t, reflect.TypeOf(unsupported1), unsupported)
 	assertCardinality(t, reflect.TypeOf(&unsupported1), unsupported)
 	assertCardinality(t, reflect.TypeOf(unsupported2), unsupported)
@@ -75,6 +79,18 @@ func TestCardinalityTextUnmarshaler(t *testing.T) {
 	assertCardinality(t, reflect.TypeOf(&m), multiple)
 }
 
+func TestIsInteger(t *testing.T) {
+	var i int
+	var u uint16
+	var x implementsTextUnmarshaler
+	assert.True(t, isInteger(reflect.TypeOf(i)))
+	assert.True(t, isInteger(reflect.TypeOf(&i)))
+	assert.True(t, isInteger(reflect.TypeOf(u)))
+	assert.False(t, isInteger(reflect.TypeOf("")))
+	assert.False(t, isInteger(reflect.TypeOf(1.5)))
+	assert.False(t, isInteger(reflect.TypeOf(x)))
+}
+
 func TestIsExported(t *testing.T) {
 	assert.True(t, isExported("Exported"))
 	assert.False(t, isExported("notExported"))
@@ -0,0 +1,175 @@
+package arg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// responseToken is a single argument read from a response file, together with
+// the line on which it started
+type responseToken struct {
+	text string
+	line int
+}
+
+// responseFileExpander replaces arguments such as "@args.txt" with the arguments
+// read from the named file
+type responseFileExpander struct {
+	prefix     string          // the prefix that marks an argument as a response file, such as "@"
+	active     map[string]bool // absolute paths of the response files currently being expanded
+	terminated bool            // true once "--" has been seen, after which nothing is expanded
+	out        []string        // the expanded arguments
+}
+
+// expandResponseFiles replaces each argument of the form <prefix><path> with the
+// arguments read from the file at that path. Response files may include other
+// response files. Arguments after "--" are not expanded.
+func expandResponseFiles(args []string, prefix string) ([]string, error) {
+	e := responseFileExpander{
+		prefix: prefix,
+		active: make(map[string]bool),
+	}
+
+	tokens := make([]responseToken, len(args))
+	for i, arg := range args {
+		tokens[i] = responseToken{text: arg}
+	}
+	if err := e.expand(tokens, ""); err != nil {
+		return nil, err
+	}
+	return e.out, nil
+}
+
+// expand processes a list of tokens that came from the given file, or from the
+// command line if file is empty
+func (e *responseFileExpander) expand(tokens []responseToken, file string) error {
+	for _, tok := range tokens {
+		if e.terminated || tok.text == e.prefix || !strings.HasPrefix(tok.text, e.prefix) {
+			if tok.text == "--" {
+				e.terminated = true
+			}
+			e.out = append(e.out, tok.text)
+			continue
+		}
+
+		// the location of the reference, for use in error messages
+		var loc string
+		if file != "" {
+			loc = fmt.Sprintf("%s:%d: ", file, tok.line)
+		}
+
+		path := tok.text[len(e.prefix):]
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("%serror reading response file %s: %v", loc, path, err)
+		}
+		if e.active[abs] {
+			return fmt.Errorf("%sresponse file %s includes itself", loc, path)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%serror reading response file %s: %v", loc, path, err)
+		}
+
+		included, err := tokenizeResponseFile(path, string(content))
+		if err != nil {
+			return err
+		}
+
+		e.active[abs] = true
+		err = e.expand(included, path)
+		delete(e.active, abs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenizeResponseFile splits the contents of a response file into arguments.
+// Arguments are separated by whitespace, single quotes preserve everything up
+// to the closing quote, double quotes do the same except that \" and \\ are
+// escapes, a backslash outside quotes escapes the next character, and a # at
+// the start of an argument begins a comment that runs to the end of the line.
+func tokenizeResponseFile(name, content string) ([]responseToken, error) {
+	var tokens []responseToken
+	var cur strings.Builder
+	var inToken bool
+	var tokenLine int
+	var quote rune
+	var quoteLine int
+
+	line := 1
+	start := func() {
+		if !inToken {
+			inToken = true
+			tokenLine = line
+		}
+	}
+
+	runes := []rune(content)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				cur.WriteRune(runes[i])
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			start()
+			quote = r
+			quoteLine = line
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' {
+					// a backslash at the end of a line continues the line
+					line++
+					continue
+				}
+				start()
+				cur.WriteRune(runes[i])
+			}
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, responseToken{text: cur.String(), line: tokenLine})
+				cur.Reset()
+				inToken = false
+			}
+		case r == '#' && !inToken:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		default:
+			start()
+			cur.WriteRune(r)
+		}
+
+		if r == '\n' {
+			line++
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("%s:%d: unterminated quote", name, quoteLine)
+	}
+	if inToken {
+		tokens = append(tokens, responseToken{text: cur.String(), line: tokenLine})
+	}
+	return tokens, nil
+}
@@ -0,0 +1,135 @@
+package arg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeResponseFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestTokenizeResponseFile(t *testing.T) {
+	content := `--foo bar   # a comment
+'single quoted' "double \"quoted\"" back\ slash
+two\
+parts x#y
+`
+	tokens, err := tokenizeResponseFile("args.txt", content)
+	require.NoError(t, err)
+
+	var texts []string
+	var lines []int
+	for _, tok := range tokens {
+		texts = append(texts, tok.text)
+		lines = append(lines, tok.line)
+	}
+	assert.Equal(t, []string{"--foo", "bar", "single quoted", `double "quoted"`, "back slash", "twoparts", "x#y"}, texts)
+	assert.Equal(t, []int{1, 1, 2, 2, 2, 3, 4}, lines)
+}
+
+func TestTokenizeResponseFileEmptyQuotes(t *testing.T) {
+	tokens, err := tokenizeResponseFile("args.txt", `--name ""`)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "", tokens[1].text)
+}
+
+func TestTokenizeResponseFileUnterminatedQuote(t *testing.T) {
+	_, err := tokenizeResponseFile("args.txt", "--foo\n'bar\nbaz")
+	assert.EqualError(t, err, "args.txt:2: unterminated quote")
+}
+
+func TestResponseFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writeResponseFile(t, dir, "args.txt", "--foo 'hello world'\n--bar 3\n")
+
+	var args struct {
+		Foo string
+		Bar int
+		Baz bool
+	}
+	_, err := parseWithEnv(Config{ResponseFilePrefix: "@"}, "@"+path+" --baz", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", args.Foo)
+	assert.Equal(t, 3, args.Bar)
+	assert.True(t, args.Baz)
+}
+
+func TestResponseFileNotEnabled(t *testing.T) {
+	var args struct {
+		Input string `arg:"positional"`
+	}
+	err := parse("@args.txt", &args)
+	require.NoError(t, err)
+	assert.Equal(t, "@args.txt", args.Input)
+}
+
+func TestResponseFileNested(t *testing.T) {
+	dir := t.TempDir()
+	inner := writeResponseFile(t, dir, "inner.txt", "--bar 3")
+	outer := writeResponseFile(t, dir, "outer.txt", "--foo x @"+inner)
+
+	var args struct {
+		Foo string
+		Bar int
+	}
+	_, err := parseWithEnv(Config{ResponseFilePrefix: "@"}, "@"+outer, nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "x", args.Foo)
+	assert.Equal(t, 3, args.Bar)
+}
+
+func TestResponseFileCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := writeResponseFile(t, dir, "b.txt", "--foo x\n@"+a)
+	writeResponseFile(t, dir, "a.txt", "@"+b)
+
+	var args struct {
+		Foo string
+	}
+	_, err := parseWithEnv(Config{ResponseFilePrefix: "@"}, "@"+a, nil, &args)
+	assert.EqualError(t, err, b+":2: response file "+a+" includes itself")
+}
+
+func TestResponseFileSameFileTwice(t *testing.T) {
+	dir := t.TempDir()
+	path := writeResponseFile(t, dir, "args.txt", "--foo x")
+
+	var args struct {
+		Foo []string `arg:"separate"`
+	}
+	_, err := parseWithEnv(Config{ResponseFilePrefix: "@"}, "@"+path+" @"+path, nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x", "x"}, args.Foo)
+}
+
+func TestResponseFileMissing(t *testing.T) {
+	var args struct {
+		Foo string
+	}
+	_, err := parseWithEnv(Config{ResponseFilePrefix: "@"}, "@does-not-exist.txt", nil, &args)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error reading response file does-not-exist.txt")
+}
+
+func TestResponseFileAfterDoubleDash(t *testing.T) {
+	dir := t.TempDir()
+	path := writeResponseFile(t, dir, "args.txt", "--foo x -- @literal")
+
+	var args struct {
+		Foo   string
+		Files []string `arg:"positional"`
+	}
+	_, err := parseWithEnv(Config{ResponseFilePrefix: "@"}, "@"+path+" @"+path, nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "x", args.Foo)
+	assert.Equal(t, []string{"@literal", "@" + path}, args.Files)
+}
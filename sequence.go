@@ -8,7 +8,7 @@ import (
 	scalar "github.com/alexflint/go-scalar"
 )
 
-// setSliceOrMap parses a sequence of strings into a slice or map. If clear is
+// setSliceOrMap parses a sequence of strings into a slice, array, or map. If clear is
 // true then any values already in the slice or map are first removed.
 func setSliceOrMap(dest reflect.Value, values []string, clear bool) error {
 	if !dest.CanSet() {
@@ -17,6 +17,9 @@ func setSliceOrMap(dest reflect.Value, values []string, clear bool) error {
 
 	t := dest.Type()
 	if t.Kind() == reflect.Ptr {
+		if dest.IsNil() {
+			dest.Set(reflect.New(t.Elem()))
+		}
 		dest = dest.Elem()
 		t = t.Elem()
 	}
@@ -24,6 +27,8 @@ func setSliceOrMap(dest reflect.Value, values []string, clear bool) error {
 	switch t.Kind() {
 	case reflect.Slice:
 		return setSlice(dest, values, clear)
+	case reflect.Array:
+		return setArray(dest, values)
 	case reflect.Map:
 		return setMap(dest, values, clear)
 	default:
@@ -60,6 +65,36 @@ func setSlice(dest reflect.Value, values []string, clear bool) error {
 	return nil
 }
 
+// setArray parses a sequence of strings into the elements of an array. The
+// number of strings must equal the length of the array.
+func setArray(dest reflect.Value, values []string) error {
+	if len(values) != dest.Len() {
+		return fmt.Errorf("expected %d values but got %d", dest.Len(), len(values))
+	}
+
+	var ptr bool
+	elem := dest.Type().Elem()
+	if elem.Kind() == reflect.Ptr && !elem.Implements(textUnmarshalerType) {
+		ptr = true
+		elem = elem.Elem()
+	}
+
+	// parse into a new array so that dest is unchanged if there is an error
+	arr := reflect.New(dest.Type()).Elem()
+	for i, s := range values {
+		v := reflect.New(elem)
+		if err := scalar.ParseValue(v.Elem(), s); err != nil {
+			return err
+		}
+		if !ptr {
+			v = v.Elem()
+		}
+		arr.Index(i).Set(v)
+	}
+	dest.Set(arr)
+	return nil
+}
+
 // setMap parses a sequence of name=value strings and inserts them into a map.
 // If clear is true then any values already in the map are removed.
 func setMap(dest reflect.Value, values []string, clear bool) error {
@@ -150,3 +150,26 @@ func TestSetSliceOrMapErrors(t *testing.T) {
 	err = setSliceOrMap(dest, nil, false)
 	assert.Error(t, err)
 }
+
+func TestSetArray(t *testing.T) {
+	var xs [3]int
+	entries := []string{"1", "2", "3"}
+	err := setSliceOrMap(reflect.ValueOf(&xs).Elem(), entries, true)
+	require.NoError(t, err)
+	assert.Equal(t, [3]int{1, 2, 3}, xs)
+}
+
+func TestSetArrayWrongLength(t *testing.T) {
+	var xs [3]int
+	entries := []string{"1", "2"}
+	err := setSliceOrMap(reflect.ValueOf(&xs).Elem(), entries, true)
+	assert.Error(t, err)
+}
+
+func TestSetArrayInvalid(t *testing.T) {
+	xs := [2]int{7, 8}
+	entries := []string{"1", "x"}
+	err := setSliceOrMap(reflect.ValueOf(&xs).Elem(), entries, true)
+	assert.Error(t, err)
+	assert.Equal(t, [2]int{7, 8}, xs)
+}
@@ -0,0 +1,257 @@
+package arg
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Source supplies values for options that were not given on the command line, such
+// as values read from a key-value store, a directory of secret files, or an
+// in-memory map in tests.
+type Source interface {
+	// Lookup gets the values for an option. It returns found=false if the source
+	// has no value for the option. Options that take a single value must be given
+	// exactly one value.
+	Lookup(opt Option) (values []string, found bool, err error)
+}
+
+// PrioritizedSource is a Source together with the priority at which it is
+// consulted. See Config.Sources.
+type PrioritizedSource struct {
+	Source   Source
+	Priority int
+}
+
+// Priorities of the built-in sources. Values from the command line always take
+// precedence over all sources.
+const (
+	DefaultPriority    = 100 // default values from struct tags or initial field values
+	ConfigFilePriority = 200 // values from config files
+	EnvPriority        = 300 // environment variables
+)
+
+// valueSource is a source as seen by the parser. The built-in sources implement it
+// directly so that they can store values in their own way and describe problems
+// in their own terms. Each Source in Config.Sources is wrapped in an externalSource.
+type valueSource interface {
+	// capture stores the value for a spec in its field and describes where it came
+	// from, returning found=false if the source has no value for it
+	capture(p *Parser, spec *spec) (origin Origin, found bool, err error)
+}
+
+// envSource reads values from environment variables
+type envSource struct{}
+
+func (envSource) capture(p *Parser, spec *spec) (Origin, bool, error) {
+	if spec.env == "" {
+		return Origin{}, false, nil
+	}
+	value, found := os.LookupEnv(spec.env)
+	if !found {
+		return Origin{}, false, nil
+	}
+	origin := Origin{Kind: OriginEnv, Value: value, Index: -1, Env: spec.env}
+	return origin, true, p.captureEnvVar(spec, value)
+}
+
+// defaultSource reads values from the default tag or from the initial value of
+// the field
+type defaultSource struct{}
+
+func (defaultSource) capture(p *Parser, spec *spec) (Origin, bool, error) {
+	if !spec.defaultValue.IsValid() {
+		return Origin{}, false, nil
+	}
+	// One issue here is that if the user now modifies the value then
+	// the default value stored in the spec will be corrupted. There
+	// is no general way to "deep-copy" values in Go, and we still
+	// support the old-style method for specifying defaults as
+	// Go values assigned directly to the struct field, so we are stuck.
+	p.val(spec.dest).Set(spec.defaultValue)
+
+	origin := Origin{Kind: OriginDefault, Value: spec.defaultString, Index: -1}
+	if spec.initialDefault {
+		origin.Kind = OriginInitialValue
+	}
+	return origin, true, nil
+}
+
+// externalSource adapts a Source from Config.Sources
+type externalSource struct {
+	source Source
+}
+
+func (s externalSource) capture(p *Parser, spec *spec) (Origin, bool, error) {
+	opt := optionOf(spec)
+	values, found, err := s.source.Lookup(opt)
+	if err != nil || !found {
+		return Origin{}, found || err != nil, err
+	}
+
+	if spec.cardinality == multiple {
+		if spec.maxValues > 0 && (len(values) < spec.minValues || len(values) > spec.maxValues) {
+			err = p.countError(spec, "", spec.minValues, spec.maxValues, len(values))
+		} else {
+			err = p.setMultiple(spec, values, true)
+		}
+	} else if len(values) != 1 {
+		err = p.countError(spec, "", 1, 1, len(values))
+	} else {
+		err = p.setScalar(spec, values[0])
+	}
+	origin := Origin{Kind: OriginSource, Value: strings.Join(values, " "), Index: -1, Source: s.source}
+	if err != nil {
+		return origin, true, &ValueParseError{
+			Option:     opt,
+			Origin:     OriginSource,
+			Value:      strings.Join(values, " "),
+			Subcommand: p.subcommandPath(),
+			Source:     s.source,
+			Err:        err,
+		}
+	}
+	return origin, true, nil
+}
+
+// sourceChain gets the sources to consult for options that were not given on the
+// command line, from the highest priority to the lowest. Sources with the same
+// priority are consulted with the built-in sources first and then in the order
+// in which they appear in Config.Sources.
+func (p *Parser) sourceChain(files []*configFile) []valueSource {
+	type entry struct {
+		source   valueSource
+		priority int
+	}
+
+	var entries []entry
+	if !p.config.IgnoreEnv {
+		entries = append(entries, entry{envSource{}, EnvPriority})
+	}
+	if len(files) > 0 {
+		entries = append(entries, entry{configFileSource{files}, ConfigFilePriority})
+	}
+	if !p.config.IgnoreDefault {
+		entries = append(entries, entry{defaultSource{}, DefaultPriority})
+	}
+	for _, s := range p.config.Sources {
+		entries = append(entries, entry{externalSource{s.Source}, s.Priority})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority > entries[j].priority
+	})
+
+	chain := make([]valueSource, len(entries))
+	for i, e := range entries {
+		chain[i] = e.source
+	}
+	return chain
+}
+
+// captureSources fills in each option that was not given on the command line from
+// the first source in the chain that has a value for it, and checks that all the
+// required options were provided. Options that were given on the command line are
+// not looked up at all, so their environment variables are neither parsed nor
+// added to the values of separate slices. The specs for every selected command are
+// considered, but only the active specs receive defaults or are checked for being
+// required, and nothing is checked for being required during completion. Counters
+// that were only incremented on the command line start from the value given by a
+// source. Like fail in process, errors are either returned immediately or
+// collected into an Errors value, depending on Config.CollectErrors.
+func (p *Parser) captureSources(cmds []*command, active []*spec, wasPresent map[*spec]bool, increments map[*spec]int) error {
+	isActive := make(map[*spec]bool)
+	for _, spec := range active {
+		isActive[spec] = true
+	}
+
+	var errs Errors
+	capture := func(spec *spec, chain []valueSource) error {
+		if wasPresent[spec] && increments[spec] == 0 {
+			return nil
+		}
+
+		var found bool
+		for _, src := range chain {
+			if _, isDefault := src.(defaultSource); isDefault && (!isActive[spec] || wasPresent[spec] || spec.required) {
+				continue
+			}
+
+			origin, ok, err := src.capture(p, spec)
+			if !ok && err == nil {
+				continue
+			}
+			found = true
+
+			// a counter that was also incremented on the command line keeps its
+			// origin on the command line
+			if !wasPresent[spec] {
+				p.origins[spec] = origin
+			}
+
+			if _, isDefault := src.(defaultSource); !isDefault {
+				// a source with an invalid value still counts as present so that we
+				// do not also report the option as missing when collecting errors
+				wasPresent[spec] = true
+				for i := 0; i < increments[spec]; i++ {
+					p.incrementCounter(spec, true)
+				}
+			}
+			if err != nil {
+				if !p.config.CollectErrors {
+					return err
+				}
+				errs = append(errs, err)
+			}
+			break
+		}
+
+		if !found && !wasPresent[spec] && spec.required && isActive[spec] && !p.completing {
+			err := &RequiredArgumentError{Option: optionOf(spec), Subcommand: p.subcommandPath()}
+			if !p.config.CollectErrors {
+				return err
+			}
+			errs = append(errs, err)
+		}
+		return nil
+	}
+
+	var specs []*spec
+	for _, cmd := range cmds {
+		specs = append(specs, cmd.specs...)
+	}
+
+	// the option that names a config file is resolved first because it determines
+	// which files are consulted for everything else
+	chain := p.sourceChain(nil)
+	for _, spec := range specs {
+		if spec.configFile && isActive[spec] {
+			if err := capture(spec, chain); err != nil {
+				return err
+			}
+		}
+	}
+
+	files, err := p.loadConfigFiles(active)
+	if err != nil {
+		if !p.config.CollectErrors {
+			return err
+		}
+		errs = append(errs, err)
+	}
+
+	chain = p.sourceChain(files)
+	for _, spec := range specs {
+		if spec.configFile && isActive[spec] {
+			continue
+		}
+		if err := capture(spec, chain); err != nil {
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package arg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapSource is a Source that looks up options by long name, prefixed by the names
+// of their subcommands
+type mapSource map[string][]string
+
+func (m mapSource) Lookup(opt Option) ([]string, bool, error) {
+	key := strings.Join(append(append([]string{}, opt.Command...), opt.Long), ".")
+	values, found := m[key]
+	return values, found, nil
+}
+
+type failingSource struct{}
+
+func (failingSource) Lookup(opt Option) ([]string, bool, error) {
+	return nil, false, errors.New("source unavailable")
+}
+
+func TestSource(t *testing.T) {
+	var args struct {
+		Name  string
+		Port  int
+		Tags  []string
+		Other string
+	}
+	config := Config{Sources: []PrioritizedSource{{
+		Source:   mapSource{"name": {"alice"}, "port": {"8080"}, "tags": {"a", "b"}},
+		Priority: 250,
+	}}}
+	_, err := parseWithEnv(config, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", args.Name)
+	assert.Equal(t, 8080, args.Port)
+	assert.Equal(t, []string{"a", "b"}, args.Tags)
+	assert.Equal(t, "", args.Other)
+}
+
+func TestSourcePriority(t *testing.T) {
+	var args struct {
+		A string `arg:"env:SOURCE_PRIORITY_A"`
+		B string `arg:"env:SOURCE_PRIORITY_B"`
+		C string `default:"default"`
+		D string `default:"default"`
+	}
+	config := Config{Sources: []PrioritizedSource{
+		{Source: mapSource{"a": {"low"}, "b": {"low"}, "c": {"low"}}, Priority: DefaultPriority + 1},
+		{Source: mapSource{"b": {"high"}}, Priority: EnvPriority + 1},
+		{Source: mapSource{"d": {"lowest"}}, Priority: DefaultPriority - 1},
+	}}
+	_, err := parseWithEnv(config, "", []string{"SOURCE_PRIORITY_A=env", "SOURCE_PRIORITY_B=env"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "env", args.A)
+	assert.Equal(t, "high", args.B)
+	assert.Equal(t, "low", args.C)
+	assert.Equal(t, "default", args.D)
+}
+
+func TestSourceSamePriority(t *testing.T) {
+	var args struct {
+		A string
+	}
+	config := Config{Sources: []PrioritizedSource{
+		{Source: mapSource{"a": {"first"}}, Priority: 1},
+		{Source: mapSource{"a": {"second"}}, Priority: 1},
+	}}
+	_, err := parseWithEnv(config, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "first", args.A)
+}
+
+func TestSourceCommandLineTakesPrecedence(t *testing.T) {
+	var args struct {
+		Name string
+	}
+	config := Config{Sources: []PrioritizedSource{{Source: mapSource{"name": {"alice"}}, Priority: 1000}}}
+	_, err := parseWithEnv(config, "--name bob", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", args.Name)
+}
+
+func TestSourceSatisfiesRequired(t *testing.T) {
+	var args struct {
+		Name string `arg:"required"`
+	}
+	config := Config{Sources: []PrioritizedSource{{Source: mapSource{"name": {"alice"}}}}}
+	_, err := parseWithEnv(config, "", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", args.Name)
+}
+
+func TestSourceSubcommand(t *testing.T) {
+	type getCmd struct {
+		Item string
+	}
+	var args struct {
+		Get *getCmd `arg:"subcommand"`
+	}
+	config := Config{Sources: []PrioritizedSource{{Source: mapSource{"get.item": {"x"}}}}}
+	_, err := parseWithEnv(config, "get", nil, &args)
+	require.NoError(t, err)
+	require.NotNil(t, args.Get)
+	assert.Equal(t, "x", args.Get.Item)
+}
+
+func TestSourceCounter(t *testing.T) {
+	var args struct {
+		Verbose int `arg:"-v,counter"`
+	}
+	config := Config{Sources: []PrioritizedSource{{Source: mapSource{"verbose": {"3"}}}}}
+	_, err := parseWithEnv(config, "-vv", nil, &args)
+	require.NoError(t, err)
+	assert.Equal(t, 5, args.Verbose)
+}
+
+func TestSourceInvalidValue(t *testing.T) {
+	var args struct {
+		Port int
+	}
+	src := mapSource{"port": {"abc"}}
+	config := Config{Sources: []PrioritizedSource{{Source: src}}}
+	_, err := parseWithEnv(config, "", nil, &args)
+
+	var e *ValueParseError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "abc", e.Value)
+	assert.Equal(t, src, e.Source)
+	assert.EqualError(t, err, `error processing value for --port: strconv.ParseInt: parsing "abc": invalid syntax`)
+}
+
+func TestSourceTooManyValues(t *testing.T) {
+	var args struct {
+		Port int
+	}
+	config := Config{Sources: []PrioritizedSource{{Source: mapSource{"port": {"1", "2"}}}}}
+	_, err := parseWithEnv(config, "", nil, &args)
+	assert.EqualError(t, err, "error processing value for --port: expected a single value but got 2")
+
+	var e *TooManyValuesError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "port", e.Long)
+	assert.Equal(t, 2, e.Got)
+}
+
+func TestSourceTooFewValues(t *testing.T) {
+	var args struct {
+		Point []int `nargs:"2"`
+	}
+	config := Config{Sources: []PrioritizedSource{{Source: mapSource{"point": {"1"}}}}}
+	_, err := parseWithEnv(config, "", nil, &args)
+	assert.EqualError(t, err, "error processing value for --point: requires 2 values but got 1")
+
+	var e *MissingValueError
+	require.True(t, errors.As(err, &e))
+	assert.Empty(t, e.Arg)
+	assert.Equal(t, 1, e.Got)
+}
+
+func TestSourceLookupError(t *testing.T) {
+	var args struct {
+		Name string `arg:"required"`
+	}
+	config := Config{
+		Sources:       []PrioritizedSource{{Source: failingSource{}}},
+		CollectErrors: true,
+	}
+	_, err := parseWithEnv(config, "", nil, &args)
+	assert.EqualError(t, err, "source unavailable")
+}
+
+func TestCommandLineSkipsInvalidEnv(t *testing.T) {
+	var args struct {
+		Port int `arg:"env:SOURCE_TEST_PORT"`
+	}
+	_, err := parseWithEnv(Config{}, "--port 8080", []string{"SOURCE_TEST_PORT=abc"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, 8080, args.Port)
+
+	_, err = parseWithEnv(Config{}, "", []string{"SOURCE_TEST_PORT=abc"}, &args)
+	assert.EqualError(t, err, "error processing environment variable SOURCE_TEST_PORT: strconv.ParseInt: parsing \"abc\": invalid syntax")
+}
+
+func TestCommandLineReplacesEnvForSeparate(t *testing.T) {
+	var args struct {
+		Tags []string `arg:"--tag,separate,env:SOURCE_TEST_TAGS"`
+	}
+	_, err := parseWithEnv(Config{}, "--tag c --tag d", []string{"SOURCE_TEST_TAGS=a,b"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, args.Tags)
+
+	args.Tags = nil
+	_, err = parseWithEnv(Config{}, "", []string{"SOURCE_TEST_TAGS=a,b"}, &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, args.Tags)
+}
@@ -506,3 +506,93 @@ func TestSubcommandInvalidInternal(t *testing.T) {
 	sub := p.Subcommand()
 	assert.Nil(t, sub)
 }
+
+func TestSubcommandShortFlagClusterWithGlobal(t *testing.T) {
+	type getCmd struct {
+		Force bool `arg:"-f"`
+	}
+	var args struct {
+		Verbose bool    `arg:"-v"`
+		Get     *getCmd `arg:"subcommand"`
+	}
+
+	_, err := pparse("-vf get", &args)
+	assert.Error(t, err)
+
+	_, err = pparse("get -vf", &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	require.NotNil(t, args.Get)
+	assert.True(t, args.Get.Force)
+}
+
+func TestSubcommandNegatableGlobal(t *testing.T) {
+	type getCmd struct{}
+	var args struct {
+		Color bool    `arg:"negatable" default:"true"`
+		Get   *getCmd `arg:"subcommand"`
+	}
+
+	_, err := pparse("get --no-color", &args)
+	require.NoError(t, err)
+	assert.False(t, args.Color)
+}
+
+func TestSubcommandNegatableConflictWithGlobal(t *testing.T) {
+	type getCmd struct {
+		NoColor bool `arg:"--no-color"`
+	}
+	var args struct {
+		Color bool    `arg:"negatable"`
+		Get   *getCmd `arg:"subcommand"`
+	}
+
+	_, err := NewParser(Config{}, &args)
+	assert.Error(t, err)
+}
+
+func TestAbbreviationOfGlobalOption(t *testing.T) {
+	type getCmd struct {
+		Force bool
+	}
+	var args struct {
+		Verbose bool
+		Get     *getCmd `arg:"subcommand"`
+	}
+
+	_, err := parseWithEnv(Config{AllowAbbreviations: true}, "get --verb --fo", nil, &args)
+	require.NoError(t, err)
+	assert.True(t, args.Verbose)
+	require.NotNil(t, args.Get)
+	assert.True(t, args.Get.Force)
+}
+
+func TestSubcommandAbbreviation(t *testing.T) {
+	type listCmd struct{}
+	type getCmd struct{}
+	var args struct {
+		List *listCmd `arg:"subcommand:list|ls"`
+		Get  *getCmd  `arg:"subcommand"`
+	}
+
+	p, err := parseWithEnv(Config{AllowSubcommandAbbreviations: true}, "l", nil, &args)
+	require.NoError(t, err)
+	assert.NotNil(t, args.List)
+	assert.Equal(t, []string{"list"}, p.SubcommandNames())
+	assert.Equal(t, args.List, p.Subcommand())
+}
+
+func TestSubcommandAbbreviationAmbiguous(t *testing.T) {
+	type getCmd struct{}
+	type gcCmd struct{}
+	var args struct {
+		Get *getCmd `arg:"subcommand"`
+		Gc  *gcCmd  `arg:"subcommand"`
+	}
+
+	_, err := parseWithEnv(Config{AllowSubcommandAbbreviations: true}, "g", nil, &args)
+	assert.EqualError(t, err, "ambiguous subcommand g (could be get, gc)")
+
+	_, err = pparse("ge", &args)
+	assert.EqualError(t, err, "invalid subcommand: ge")
+}
@@ -3,12 +3,21 @@ package arg
 import (
 	"fmt"
 	"io"
+	"os"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // the width of the left column
 const colWidth = 25
 
+// the width to which help is wrapped when neither Config.HelpWidth nor COLUMNS is set
+const defaultHelpWidth = 80
+
+// the narrowest that the description column is allowed to become when wrapping
+const minDescriptionWidth = 20
+
 // Fail prints usage information to p.Config.Out and exits with status code 2.
 func (p *Parser) Fail(msg string) {
 	p.FailSubcommand(msg)
@@ -31,6 +40,23 @@ func (p *Parser) FailSubcommand(msg string, subcommand ...string) error {
 	return nil
 }
 
+// failWithErrors prints usage information for a specified subcommand to
+// p.Config.Out followed by a bulleted list of errors, then exits with status
+// code 2.
+func (p *Parser) failWithErrors(errs Errors, subcommand ...string) error {
+	err := p.WriteUsageForSubcommand(p.config.Out, subcommand...)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(p.config.Out, "error:")
+	for _, err := range errs {
+		fmt.Fprintln(p.config.Out, "  -", err)
+	}
+	p.config.Exit(2)
+	return nil
+}
+
 // WriteUsage writes usage information to the given writer
 func (p *Parser) WriteUsage(w io.Writer) {
 	p.WriteUsageForSubcommand(w, p.subcommand...)
@@ -42,6 +68,12 @@ func (p *Parser) WriteUsage(w io.Writer) {
 // that is nested under another subcommand, provide a sequence of subcommand
 // names starting with the top-level subcommand and so on down the tree.
 func (p *Parser) WriteUsageForSubcommand(w io.Writer, subcommand ...string) error {
+	return p.writeUsage(w, p.helpWidth(), subcommand...)
+}
+
+// writeUsage writes the usage information for a subcommand wrapped to the given
+// width, or on a single line if the width is zero
+func (p *Parser) writeUsage(w io.Writer, width int, subcommand ...string) error {
 	cmd, err := p.lookupCommand(subcommand...)
 	if err != nil {
 		return err
@@ -62,34 +94,40 @@ func (p *Parser) WriteUsageForSubcommand(w io.Writer, subcommand ...string) erro
 		}
 	}
 
-	// print the beginning of the usage string
-	fmt.Fprintf(w, "Usage: %s", p.cmd.name)
-	for _, s := range subcommand {
-		fmt.Fprint(w, " "+s)
-	}
+	// the words of the usage string, each of which is kept on one line when the
+	// usage string is wrapped
+	var words []string
+
+	// write the option component of the usage message, with the options in each
+	// group written together at the position of the first one, as in
+	// [--json | --yaml] for an exclusive group or (--file FILE | --url URL) for a
+	// one-required group
+	written := make(map[*group]bool)
+	for _, spec := range append(shortOptions, longOptions...) {
+		if spec.group != nil {
+			if written[spec.group] {
+				continue
+			}
+			written[spec.group] = true
 
-	// write the option component of the usage message
-	for _, spec := range shortOptions {
-		// prefix with a space
-		fmt.Fprint(w, " ")
-		if !spec.required {
-			fmt.Fprint(w, "[")
-		}
-		fmt.Fprint(w, synopsis(spec, "-"+spec.short))
-		if !spec.required {
-			fmt.Fprint(w, "]")
+			var members []string
+			for _, member := range append(shortOptions, longOptions...) {
+				if member.group == spec.group {
+					members = append(members, optionSynopsis(member))
+				}
+			}
+			if spec.group.oneRequired {
+				words = append(words, "("+strings.Join(members, " | ")+")")
+			} else {
+				words = append(words, "["+strings.Join(members, " | ")+"]")
+			}
+			continue
 		}
-	}
 
-	for _, spec := range longOptions {
-		// prefix with a space
-		fmt.Fprint(w, " ")
-		if !spec.required {
-			fmt.Fprint(w, "[")
-		}
-		fmt.Fprint(w, synopsis(spec, "--"+spec.long))
-		if !spec.required {
-			fmt.Fprint(w, "]")
+		if spec.required {
+			words = append(words, optionSynopsis(spec))
+		} else {
+			words = append(words, "["+optionSynopsis(spec)+"]")
 		}
 	}
 
@@ -107,28 +145,53 @@ func (p *Parser) WriteUsageForSubcommand(w io.Writer, subcommand ...string) erro
 	//    REQUIRED1 REQUIRED2 [OPTIONAL1 [REPEATEDOPTIONAL [REPEATEDOPTIONAL ...]]]
 	var closeBrackets int
 	for _, spec := range positionals {
-		fmt.Fprint(w, " ")
+		word := spec.placeholder
+		if spec.cardinality == multiple {
+			word = fmt.Sprintf("%s [%s ...]", spec.placeholder, spec.placeholder)
+		}
 		if !spec.required {
-			fmt.Fprint(w, "[")
+			word = "[" + word
 			closeBrackets += 1
 		}
-		if spec.cardinality == multiple {
-			fmt.Fprintf(w, "%s [%s ...]", spec.placeholder, spec.placeholder)
-		} else {
-			fmt.Fprint(w, spec.placeholder)
-		}
+		words = append(words, word)
+	}
+	if closeBrackets > 0 {
+		words[len(words)-1] += strings.Repeat("]", closeBrackets)
 	}
-	fmt.Fprint(w, strings.Repeat("]", closeBrackets))
 
 	// if the program supports subcommands, give a hint to the user about their existence
 	if len(cmd.subcommands) > 0 {
-		fmt.Fprint(w, " <command> [<args>]")
+		words = append(words, "<command> [<args>]")
 	}
 
-	fmt.Fprint(w, "\n")
+	lead := "Usage: " + strings.Join(append([]string{p.cmd.name}, subcommand...), " ")
+	writeUsageLine(w, lead, words, width)
 	return nil
 }
 
+// writeUsageLine writes the usage string, moving words that do not fit within the
+// width to further lines. These are indented to line up with the first word after
+// the command name, or with the command name if that would leave too little room.
+// A width of zero means that the usage string is not wrapped.
+func writeUsageLine(w io.Writer, lead string, words []string, width int) {
+	indent := stringWidth(lead) + 1
+	if indent > width/2 {
+		indent = stringWidth("Usage: ")
+	}
+
+	line, col := lead, stringWidth(lead)
+	for _, word := range words {
+		n := stringWidth(word)
+		if width > 0 && col+1+n > width {
+			fmt.Fprintln(w, line)
+			line, col = strings.Repeat(" ", indent)+word, indent+n
+			continue
+		}
+		line, col = line+" "+word, col+1+n
+	}
+	fmt.Fprintln(w, line)
+}
+
 // print prints a line like this:
 //
 //	--option FOO            A description of the option [default: 123]
@@ -141,17 +204,16 @@ func (p *Parser) WriteUsageForSubcommand(w io.Writer, subcommand ...string) erro
 // If multiple "extras" are provided then they are put inside a single set of square brackets:
 //
 //	--option FOO            A description of the option [default: 123, env: FOO]
-func print(w io.Writer, item, description string, bracketed ...string) {
+//
+// Descriptions that do not fit within the width are wrapped, and continuation lines,
+// including those that follow explicit newlines in the description, are indented to
+// the description column:
+//
+//	--option FOO            A description of the option that goes on for
+//	                        longer than the width [default: 123]
+func print(w io.Writer, width int, item, description string, bracketed ...string) {
 	lhs := "  " + item
 	fmt.Fprint(w, lhs)
-	if description != "" {
-		if len(lhs)+2 < colWidth {
-			fmt.Fprint(w, strings.Repeat(" ", colWidth-len(lhs)))
-		} else {
-			fmt.Fprint(w, "\n"+strings.Repeat(" ", colWidth))
-		}
-		fmt.Fprint(w, description)
-	}
 
 	var brack string
 	for _, s := range bracketed {
@@ -163,12 +225,116 @@ func print(w io.Writer, item, description string, bracketed ...string) {
 		}
 	}
 
+	if description == "" {
+		if brack != "" {
+			fmt.Fprintf(w, " [%s]", brack)
+		}
+		fmt.Fprint(w, "\n")
+		return
+	}
+
+	if n := stringWidth(lhs); n+2 < colWidth {
+		fmt.Fprint(w, strings.Repeat(" ", colWidth-n))
+	} else {
+		fmt.Fprint(w, "\n"+strings.Repeat(" ", colWidth))
+	}
+
 	if brack != "" {
-		fmt.Fprintf(w, " [%s]", brack)
+		description += " [" + brack + "]"
+	}
+	for i, line := range wrapText(description, width-colWidth) {
+		if i > 0 {
+			fmt.Fprint(w, "\n")
+			if line != "" {
+				fmt.Fprint(w, strings.Repeat(" ", colWidth))
+			}
+		}
+		fmt.Fprint(w, line)
 	}
 	fmt.Fprint(w, "\n")
 }
 
+// wrapText splits text into lines at its explicit newlines and then wherever a line
+// is wider than the width, breaking between words. Lines that fit are left as they
+// are. The width is not allowed to go below minDescriptionWidth.
+func wrapText(s string, width int) []string {
+	if width < minDescriptionWidth {
+		width = minDescriptionWidth
+	}
+
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		if stringWidth(paragraph) <= width {
+			lines = append(lines, paragraph)
+			continue
+		}
+
+		var line string
+		var col int
+		for _, word := range strings.Fields(paragraph) {
+			n := stringWidth(word)
+			if line != "" && col+1+n > width {
+				lines = append(lines, line)
+				line, col = "", 0
+			}
+			if line != "" {
+				line, col = line+" ", col+1
+			}
+			line, col = line+word, col+n
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// helpWidth gets the width to which help and usage text is wrapped
+func (p *Parser) helpWidth() int {
+	if p.config.HelpWidth > 0 {
+		return p.config.HelpWidth
+	}
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return defaultHelpWidth
+}
+
+// stringWidth gets the number of columns that a string takes up in a terminal
+func stringWidth(s string) int {
+	var n int
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+	return n
+}
+
+// runeWidth gets the number of columns that a rune takes up in a terminal, which
+// is zero for control characters and combining marks, two for wide East Asian
+// characters and emoji, and one for everything else
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.IsControl(r):
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	case r >= 0x1100 && r <= 0x115f, // Hangul Jamo
+		r >= 0x2e80 && r <= 0x303e,   // CJK radicals and punctuation
+		r >= 0x3041 && r <= 0x33ff,   // kana and CJK compatibility
+		r >= 0x3400 && r <= 0x4dbf,   // CJK extension A
+		r >= 0x4e00 && r <= 0x9fff,   // CJK unified ideographs
+		r >= 0xa000 && r <= 0xa4cf,   // Yi
+		r >= 0xac00 && r <= 0xd7a3,   // Hangul syllables
+		r >= 0xf900 && r <= 0xfaff,   // CJK compatibility ideographs
+		r >= 0xfe30 && r <= 0xfe4f,   // CJK compatibility forms
+		r >= 0xff00 && r <= 0xff60,   // fullwidth forms
+		r >= 0xffe0 && r <= 0xffe6,   // fullwidth signs
+		r >= 0x1f300 && r <= 0x1f64f, // emoji
+		r >= 0x1f900 && r <= 0x1f9ff, // more emoji
+		r >= 0x20000 && r <= 0x3fffd: // CJK extensions B and later
+		return 2
+	}
+	return 1
+}
+
 func withDefault(s string) string {
 	if s == "" {
 		return ""
@@ -244,13 +410,15 @@ func (p *Parser) WriteHelpForSubcommand(w io.Writer, subcommand ...string) error
 		fmt.Fprintln(w, p.version)
 	}
 
-	p.WriteUsageForSubcommand(w, subcommand...)
+	// COLUMNS is read once for the whole help text
+	width := p.helpWidth()
+	p.writeUsage(w, width, subcommand...)
 
 	// write the list of positionals
 	if len(positionals) > 0 {
 		fmt.Fprint(w, "\nPositional arguments:\n")
 		for _, spec := range positionals {
-			print(w, spec.placeholder, spec.help, withDefault(spec.defaultString), withEnv(spec.env))
+			print(w, width, spec.placeholder, spec.help, withDefault(spec.defaultString), withEnv(spec.env), withChoices(spec.choices), withDependencies(spec))
 		}
 	}
 
@@ -258,10 +426,10 @@ func (p *Parser) WriteHelpForSubcommand(w io.Writer, subcommand ...string) error
 	if len(shortOptions)+len(longOptions) > 0 || cmd.parent == nil {
 		fmt.Fprint(w, "\nOptions:\n")
 		for _, spec := range shortOptions {
-			p.printOption(w, spec)
+			p.printOption(w, width, spec)
 		}
 		for _, spec := range longOptions {
-			p.printOption(w, spec)
+			p.printOption(w, width, spec)
 		}
 	}
 
@@ -269,19 +437,19 @@ func (p *Parser) WriteHelpForSubcommand(w io.Writer, subcommand ...string) error
 	if len(globals) > 0 {
 		fmt.Fprint(w, "\nGlobal options:\n")
 		for _, spec := range globals {
-			p.printOption(w, spec)
+			p.printOption(w, width, spec)
 		}
 	}
 
 	// write the list of built in options
-	p.printOption(w, &spec{
+	p.printOption(w, width, &spec{
 		cardinality: zero,
 		long:        "help",
 		short:       "h",
 		help:        "display this help and exit",
 	})
 	if !hasVersionOption && p.version != "" {
-		p.printOption(w, &spec{
+		p.printOption(w, width, &spec{
 			cardinality: zero,
 			long:        "version",
 			help:        "display version and exit",
@@ -292,7 +460,7 @@ func (p *Parser) WriteHelpForSubcommand(w io.Writer, subcommand ...string) error
 	if len(envOnlyOptions) > 0 {
 		fmt.Fprint(w, "\nEnvironment variables:\n")
 		for _, spec := range envOnlyOptions {
-			p.printEnvOnlyVar(w, spec)
+			p.printEnvOnlyVar(w, width, spec)
 		}
 	}
 
@@ -306,7 +474,7 @@ func (p *Parser) WriteHelpForSubcommand(w io.Writer, subcommand ...string) error
 			}
 
 			names := append([]string{subcmd.name}, subcmd.aliases...)
-			print(w, strings.Join(names, ", "), subcmd.help)
+			print(w, width, strings.Join(names, ", "), subcmd.help)
 		}
 	}
 
@@ -316,20 +484,22 @@ func (p *Parser) WriteHelpForSubcommand(w io.Writer, subcommand ...string) error
 	return nil
 }
 
-func (p *Parser) printOption(w io.Writer, spec *spec) {
+func (p *Parser) printOption(w io.Writer, width int, spec *spec) {
 	ways := make([]string, 0, 2)
-	if spec.long != "" {
+	if spec.long != "" && spec.negatable {
+		ways = append(ways, "--[no-]"+spec.long)
+	} else if spec.long != "" {
 		ways = append(ways, synopsis(spec, "--"+spec.long))
 	}
 	if spec.short != "" {
 		ways = append(ways, synopsis(spec, "-"+spec.short))
 	}
 	if len(ways) > 0 {
-		print(w, strings.Join(ways, ", "), spec.help, withDefault(spec.defaultString), withEnv(spec.env))
+		print(w, width, strings.Join(ways, ", "), spec.help, withDefault(spec.defaultString), withEnv(spec.env), withChoices(spec.choices), withDependencies(spec))
 	}
 }
 
-func (p *Parser) printEnvOnlyVar(w io.Writer, spec *spec) {
+func (p *Parser) printEnvOnlyVar(w io.Writer, width int, spec *spec) {
 	ways := make([]string, 0, 2)
 	if spec.required {
 		ways = append(ways, "Required.")
@@ -341,7 +511,15 @@ func (p *Parser) printEnvOnlyVar(w io.Writer, spec *spec) {
 		ways = append(ways, spec.help)
 	}
 
-	print(w, spec.env, strings.Join(ways, " "), withDefault(spec.defaultString))
+	print(w, width, spec.env, strings.Join(ways, " "), withDefault(spec.defaultString))
+}
+
+// optionSynopsis gets the synopsis of an option using its long name if it has one
+func optionSynopsis(spec *spec) string {
+	if spec.long != "" {
+		return synopsis(spec, "--"+spec.long)
+	}
+	return synopsis(spec, "-"+spec.short)
 }
 
 func synopsis(spec *spec, form string) string {
@@ -351,5 +529,21 @@ func synopsis(spec *spec, form string) string {
 	if spec.cardinality == zero || spec.placeholder == "" {
 		return form
 	}
+	if spec.optionalValue {
+		return form + "[=" + spec.placeholder + "]"
+	}
+	// options with a fixed number of values repeat the placeholder once for each
+	// value, unless the user has given a placeholder such as "X Y" for all of them
+	if spec.maxValues > 0 && !strings.Contains(spec.placeholder, " ") {
+		words := make([]string, 0, spec.maxValues)
+		for i := 0; i < spec.maxValues; i++ {
+			if i < spec.minValues {
+				words = append(words, spec.placeholder)
+			} else {
+				words = append(words, "["+spec.placeholder+"]")
+			}
+		}
+		return form + " " + strings.Join(words, " ")
+	}
 	return form + " " + spec.placeholder
 }
@@ -33,10 +33,16 @@ func (n *NameDotName) MarshalText() (text []byte, err error) {
 }
 
 func TestWriteUsage(t *testing.T) {
-	expectedUsage := "Usage: example [--name NAME] [--value VALUE] [--verbose] [--dataset DATASET] [--optimize OPTIMIZE] [--ids IDS] [--values VALUES] [--workers WORKERS] [--testenv TESTENV] [--file FILE] INPUT [OUTPUT [OUTPUT ...]]"
+	expectedUsage := `Usage: example [--name NAME] [--value VALUE] [--verbose] [--dataset DATASET]
+               [--optimize OPTIMIZE] [--ids IDS] [--values VALUES]
+               [--workers WORKERS] [--testenv TESTENV] [--file FILE] INPUT
+               [OUTPUT [OUTPUT ...]]`
 
 	expectedHelp := `
-Usage: example [--name NAME] [--value VALUE] [--verbose] [--dataset DATASET] [--optimize OPTIMIZE] [--ids IDS] [--values VALUES] [--workers WORKERS] [--testenv TESTENV] [--file FILE] INPUT [OUTPUT [OUTPUT ...]]
+Usage: example [--name NAME] [--value VALUE] [--verbose] [--dataset DATASET]
+               [--optimize OPTIMIZE] [--ids IDS] [--values VALUES]
+               [--workers WORKERS] [--testenv TESTENV] [--file FILE] INPUT
+               [OUTPUT [OUTPUT ...]]
 
 Positional arguments:
   INPUT
@@ -81,7 +87,7 @@ Environment variables:
 	args.Name = "Foo Bar"
 	args.Value = 42
 	args.File = &NameDotName{"scratch", "txt"}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	os.Args[0] = "example"
@@ -121,7 +127,7 @@ Options:
 		Content string `default:"dog"`
 	}
 	args.Label = "cat"
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	args.Label = "should_ignore_this"
@@ -153,7 +159,8 @@ Usage: example [VERYLONGPOSITIONALWITHHELP]
 
 Positional arguments:
   VERYLONGPOSITIONALWITHHELP
-                         this positional argument is very long but cannot include commas
+                         this positional argument is very long but cannot
+                         include commas
 
 Options:
   --help, -h             display this help and exit
@@ -162,7 +169,7 @@ Options:
 		VeryLongPositionalWithHelp string `arg:"positional,help:this positional argument is very long but cannot include commas"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -182,7 +189,8 @@ Usage: example [VERYLONGPOSITIONALWITHHELP]
 
 Positional arguments:
   VERYLONGPOSITIONALWITHHELP
-                         this positional argument is very long, and includes: commas, colons etc
+                         this positional argument is very long, and includes:
+                         commas, colons etc
 
 Options:
   --help, -h             display this help and exit
@@ -191,7 +199,7 @@ Options:
 		VeryLongPositionalWithHelp string `arg:"positional" help:"this positional argument is very long, and includes: commas, colons etc"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -213,7 +221,8 @@ Options:
   --help, -h             display this help and exit
 `
 	config := Config{
-		Program: "myprogram",
+		Program:   "myprogram",
+		HelpWidth: 80,
 	}
 	p, err := NewParser(config, &struct{}{})
 	require.NoError(t, err)
@@ -248,7 +257,7 @@ Options:
   --version              display version and exit
 `
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &versioned{})
+	p, err := NewParser(Config{HelpWidth: 80}, &versioned{})
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -276,7 +285,7 @@ Options:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -305,7 +314,7 @@ Options:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -342,7 +351,7 @@ Commands:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -396,7 +405,7 @@ Commands:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -452,7 +461,7 @@ Commands:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -505,7 +514,7 @@ Options:
   --help, -h             display this help and exit
 `
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &described{})
+	p, err := NewParser(Config{HelpWidth: 80}, &described{})
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -536,7 +545,7 @@ Options:
 For more information visit github.com/alexflint/go-arg
 `
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &epilogued{})
+	p, err := NewParser(Config{HelpWidth: 80}, &epilogued{})
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -555,7 +564,7 @@ func TestUsageForRequiredPositionals(t *testing.T) {
 		Required2 string `arg:"positional,required"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var usage bytes.Buffer
@@ -572,7 +581,7 @@ func TestUsageForMixedPositionals(t *testing.T) {
 		Optional2 string `arg:"positional"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var usage bytes.Buffer
@@ -588,7 +597,7 @@ func TestUsageForRepeatedPositionals(t *testing.T) {
 		Repeated  []string `arg:"positional,required"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var usage bytes.Buffer
@@ -597,7 +606,7 @@ func TestUsageForRepeatedPositionals(t *testing.T) {
 }
 
 func TestUsageForMixedAndRepeatedPositionals(t *testing.T) {
-	expectedUsage := "Usage: example REQUIRED1 REQUIRED2 [OPTIONAL1 [OPTIONAL2 [REPEATED [REPEATED ...]]]]\n"
+	expectedUsage := "Usage: example REQUIRED1 REQUIRED2 [OPTIONAL1 [OPTIONAL2\n               [REPEATED [REPEATED ...]]]]\n"
 	var args struct {
 		Required1 string   `arg:"positional,required"`
 		Required2 string   `arg:"positional,required"`
@@ -606,7 +615,7 @@ func TestUsageForMixedAndRepeatedPositionals(t *testing.T) {
 		Repeated  []string `arg:"positional"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var usage bytes.Buffer
@@ -630,7 +639,7 @@ Options:
 		RequiredMultiple []string `arg:"positional,required" help:"required multiple positional"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -664,7 +673,7 @@ Global options:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	_ = p.Parse([]string{"child"})
@@ -716,7 +725,7 @@ Global options:
 	}
 
 	os.Args[0] = "example"
-	p, err := NewParser(Config{}, &args)
+	p, err := NewParser(Config{HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	_ = p.Parse([]string{"child", "nested", "value"})
@@ -783,7 +792,7 @@ Options:
 		ShortOnly  string `arg:"-a,--" help:"some help" default:"some val" placeholder:"PLACEHOLDER"`
 		ShortOnly2 string `arg:"-b,--,required" help:"some help2"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -812,7 +821,7 @@ Options:
 		LongOnly  string `arg:"--b" placeholder:"" help:"some help for b"`
 		Both      string `arg:"-c,--c" placeholder:"" help:"some help for c"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -839,7 +848,7 @@ Options:
 		Dog string
 		Cat string `arg:"-c,--"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	assert.NoError(t, err)
 
 	var help bytes.Buffer
@@ -873,7 +882,7 @@ Environment variables:
 		EnvOnlyOverriden string `arg:"--,env:CUSTOM"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	assert.NoError(t, err)
 
 	var help bytes.Buffer
@@ -902,7 +911,7 @@ Environment variables:
 		ArgParam string `arg:"-a,--arg,env:MY_ARG"`
 		AuthKey  string `arg:"required,--,env:AUTH_KEY"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	assert.NoError(t, err)
 
 	var help bytes.Buffer
@@ -958,6 +967,34 @@ error: something went wrong
 	assert.Equal(t, 2, exitCode)
 }
 
+func TestMustParsePrintsCollectedErrors(t *testing.T) {
+	originalArgs := os.Args
+	defer func() {
+		os.Args = originalArgs
+	}()
+
+	var stdout bytes.Buffer
+	var exitCode int
+	exit := func(code int) { exitCode = code }
+
+	expectedStdout := `
+Usage: example --name NAME [--count COUNT]
+error:
+  - unknown argument --bogus
+  - NAME is required
+`
+
+	var args struct {
+		Name  string `arg:"required"`
+		Count int
+	}
+	os.Args = []string{"example", "--bogus"}
+	mustParse(Config{Program: "example", Exit: exit, Out: &stdout, CollectErrors: true, HelpWidth: 80}, &args)
+
+	assert.Equal(t, expectedStdout[1:], stdout.String())
+	assert.Equal(t, 2, exitCode)
+}
+
 type lengthOf struct {
 	Length int
 }
@@ -982,7 +1019,7 @@ Options:
 	var args struct {
 		Test *lengthOf `default:"some_default_value"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -1008,7 +1045,7 @@ Commands:
 		Simple *struct{} `arg:"subcommand" help:"do something simple"`
 		Stop   *struct{} `arg:"subcommand:halt|stop" help:"stop now"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -1033,7 +1070,7 @@ Commands:
 		Simple *struct{} `arg:"subcommand,hidden" help:"simple hidden subcommand"`
 		Stop   *struct{} `arg:"subcommand:halt|stop" help:"stop now"`
 	}
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -1056,7 +1093,7 @@ Options:
 		Foo string `arg:"positional" default:"bar" help:"this is a positional with a default"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -1080,7 +1117,7 @@ Options:
 		Bar string `arg:"positional,hidden" default:"baz" help:"this is a hidden positional with a default"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -1103,7 +1140,7 @@ Options:
 		Foo string `arg:"positional,env:FOO" help:"this is a positional with an env variable"`
 	}
 
-	p, err := NewParser(Config{Program: "example"}, &args)
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
 	require.NoError(t, err)
 
 	var help bytes.Buffer
@@ -1116,7 +1153,8 @@ func TestHelpShowsPositionalWithDefaultAndEnv(t *testing.T) {
 Usage: example [FOO]
 
 Positional arguments:
-  FOO                    this is a positional with a default and an env variable [default: bar, env: FOO]
+  FOO                    this is a positional with a default and an env variable
+                         [default: bar, env: FOO]
 
 Options:
   --help, -h             display this help and exit
@@ -1126,10 +1164,316 @@ Options:
 		Foo string `arg:"positional,env:FOO" default:"bar" help:"this is a positional with a default and an env variable"`
 	}
 
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+}
+
+func TestHelpShowsCounterWithoutPlaceholder(t *testing.T) {
+	expectedUsage := "Usage: example [--verbose]"
+
+	expectedHelp := `
+Usage: example [--verbose]
+
+Options:
+  --verbose, -v          increase verbosity [default: 1]
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Verbose int `arg:"-v,counter" default:"1" help:"increase verbosity"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+
+	var usage bytes.Buffer
+	p.WriteUsage(&usage)
+	assert.Equal(t, expectedUsage, strings.TrimSpace(usage.String()))
+}
+
+func TestHelpShowsNegatable(t *testing.T) {
+	expectedUsage := "Usage: example [--color] [--quiet]"
+
+	expectedHelp := `
+Usage: example [--color] [--quiet]
+
+Options:
+  --[no-]color           colorize output [default: true]
+  --quiet, -q            suppress output
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Color bool `arg:"negatable" default:"true" help:"colorize output"`
+		Quiet bool `arg:"-q" help:"suppress output"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+
+	var usage bytes.Buffer
+	p.WriteUsage(&usage)
+	assert.Equal(t, expectedUsage, strings.TrimSpace(usage.String()))
+}
+
+func TestHelpShowsOptionalValue(t *testing.T) {
+	expectedUsage := "Usage: example [-l[=LEVEL]] [--color[=WHEN]]"
+
+	expectedHelp := `
+Usage: example [-l[=LEVEL]] [--color[=WHEN]]
+
+Options:
+  -l[=LEVEL]             log level
+  --color[=WHEN]         when to use color [default: never]
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Color string `implicit:"auto" default:"never" placeholder:"WHEN" help:"when to use color"`
+		Level int    `arg:"-l,--" implicit:"1" help:"log level"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+
+	var usage bytes.Buffer
+	p.WriteUsage(&usage)
+	assert.Equal(t, expectedUsage, strings.TrimSpace(usage.String()))
+}
+
+func TestHelpShowsFixedArity(t *testing.T) {
+	expectedUsage := "Usage: example [--point X Y] [--resize SIZE SIZE [SIZE]]"
+
+	expectedHelp := `
+Usage: example [--point X Y] [--resize SIZE SIZE [SIZE]]
+
+Options:
+  --point X Y            a point
+  --resize SIZE SIZE [SIZE]
+                         new size
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Point  [2]float64 `placeholder:"X Y" help:"a point"`
+		Resize []int      `nargs:"2..3" placeholder:"SIZE" help:"new size"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+
+	var usage bytes.Buffer
+	p.WriteUsage(&usage)
+	assert.Equal(t, expectedUsage, strings.TrimSpace(usage.String()))
+}
+
+func TestUsageShowsGroups(t *testing.T) {
+	expectedUsage := "Usage: example [-v] [--json | --yaml | --table] (--file FILE | --url URL)\n               [--out OUT]"
+
+	var args struct {
+		Verbose bool   `arg:"-v,--"`
+		JSON    bool   `arg:"group:output,exclusive"`
+		File    string `arg:"group:input,one-required"`
+		YAML    bool   `arg:"group:output"`
+		URL     string `arg:"group:input"`
+		Table   bool   `arg:"group:output"`
+		Out     string
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var usage bytes.Buffer
+	p.WriteUsage(&usage)
+	assert.Equal(t, expectedUsage, strings.TrimSpace(usage.String()))
+}
+
+func TestHelpShowsDependencies(t *testing.T) {
+	expectedHelp := `
+Usage: example [--tls-key TLS-KEY] [--tls-cert TLS-CERT] [--output OUTPUT]
+               [--stdout]
+
+Options:
+  --tls-key TLS-KEY      private key [requires: --tls-cert]
+  --tls-cert TLS-CERT    certificate
+  --output OUTPUT        output file [env: OUTPUT, required unless: --stdout]
+  --stdout               write to stdout
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		TLSKey  string `arg:"--tls-key,requires:tls-cert" help:"private key"`
+		TLSCert string `arg:"--tls-cert" help:"certificate"`
+		Output  string `arg:"env,required-unless:stdout" help:"output file"`
+		Stdout  bool   `help:"write to stdout"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+}
+
+func TestHelpShowsChoices(t *testing.T) {
+	expectedHelp := `
+Usage: example [--level LEVEL]
+
+Options:
+  --level LEVEL          log level [default: info, env: LEVEL, choices: debug,
+                         info, warn, error]
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Level string `arg:"env" choices:"debug|info|warn|error" default:"info" help:"log level"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+}
+
+func TestHelpWrapsToHelpWidth(t *testing.T) {
+	expectedHelp := `
+Usage: example [--name NAME]
+               [--count COUNT]
+
+Options:
+  --name NAME            the name of the
+                         thing to create
+                         [default: foo]
+  --count COUNT          how many
+                         first line
+                         second line
+  --help, -h             display this help
+                         and exit
+`
+
+	var args struct {
+		Name  string `default:"foo" help:"the name of the thing to create"`
+		Count int    `help:"how many\nfirst line\nsecond line"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 40}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+}
+
+func TestHelpWidthFromColumns(t *testing.T) {
+	t.Setenv("COLUMNS", "50")
+
+	var args struct {
+		Name string `help:"the name of the thing that is going to be created"`
+	}
+
 	p, err := NewParser(Config{Program: "example"}, &args)
 	require.NoError(t, err)
 
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Contains(t, help.String(), "  --name NAME            the name of the thing\n                         that is going to be\n                         created\n")
+
+	p, err = NewParser(Config{Program: "example", HelpWidth: 100}, &args)
+	require.NoError(t, err)
+
+	help.Reset()
+	p.WriteHelp(&help)
+	assert.Contains(t, help.String(), "  --name NAME            the name of the thing that is going to be created\n")
+}
+
+func TestHelpKeepsExplicitNewlines(t *testing.T) {
+	expectedHelp := `
+Usage: example [--mode MODE]
+
+Options:
+  --mode MODE            one of:
+
+                           fast
+                           slow
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Mode string `help:"one of:\n\n  fast\n  slow"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
 	var help bytes.Buffer
 	p.WriteHelp(&help)
 	assert.Equal(t, expectedHelp[1:], help.String())
 }
+
+func TestHelpWrapsByDisplayWidth(t *testing.T) {
+	expectedHelp := `
+Usage: example [--名前 名前]
+
+Options:
+  --名前 名前            名前を入力してください 名前を入力してください
+                         名前を入力してください
+  --help, -h             display this help and exit
+`
+
+	var args struct {
+		Name string `arg:"--名前" placeholder:"名前" help:"名前を入力してください 名前を入力してください 名前を入力してください"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 80}, &args)
+	require.NoError(t, err)
+
+	var help bytes.Buffer
+	p.WriteHelp(&help)
+	assert.Equal(t, expectedHelp[1:], help.String())
+}
+
+func TestUsageWrapsLongCommandPath(t *testing.T) {
+	var args struct {
+		Sub *struct {
+			Alpha string
+			Beta  string
+		} `arg:"subcommand:a-rather-long-subcommand-name"`
+	}
+
+	p, err := NewParser(Config{Program: "example", HelpWidth: 60}, &args)
+	require.NoError(t, err)
+
+	var usage bytes.Buffer
+	require.NoError(t, p.WriteUsageForSubcommand(&usage, "a-rather-long-subcommand-name"))
+	assert.Equal(t, "Usage: example a-rather-long-subcommand-name [--alpha ALPHA]\n       [--beta BETA]\n", usage.String())
+}
+
+func TestStringWidth(t *testing.T) {
+	assert.Equal(t, 5, stringWidth("hello"))
+	assert.Equal(t, 4, stringWidth("名前"))
+	assert.Equal(t, 4, stringWidth("café"))
+	assert.Equal(t, 2, stringWidth("🎉"))
+}
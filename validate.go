@@ -0,0 +1,356 @@
+package arg
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	scalar "github.com/alexflint/go-scalar"
+)
+
+// validationRule is a single rule from a validate tag, such as "min=1"
+type validationRule struct {
+	name    string         // the name of the rule, such as "min" or "regex"
+	arg     string         // the text after the equals sign, if any
+	bound   reflect.Value  // for min and max, the argument parsed into the type of the field
+	length  int            // for len, minlen, and maxlen, the argument as an integer
+	re      *regexp.Regexp // for regex, the compiled expression
+	options []string       // for oneof, the accepted values
+}
+
+// parseValidateTag parses a validate tag such as "min=1,max=65535" into a list of
+// rules, and checks that each rule can be used with the given field type. Rules
+// are separated by commas, except that a regex rule takes the rest of the tag so
+// that the expression may contain commas.
+func parseValidateTag(tag string, t reflect.Type) ([]*validationRule, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// element rules apply to each value of a slice or array
+	elem := t
+	isCollection := false
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem = t.Elem()
+		isCollection = true
+	case reflect.Map:
+		elem = nil
+		isCollection = true
+	}
+	if elem != nil && elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	var rules []*validationRule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(strings.TrimSpace(tag), "regex=") {
+			part, tag = tag, ""
+		} else if pos := strings.Index(tag, ","); pos != -1 {
+			part, tag = tag[:pos], tag[pos+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		rule := &validationRule{name: part}
+		if pos := strings.Index(part, "="); pos != -1 {
+			rule.name, rule.arg = part[:pos], part[pos+1:]
+		}
+
+		switch rule.name {
+		case "min", "max":
+			if elem == nil || !isNumeric(elem) {
+				return nil, fmt.Errorf("%s can only be used with numeric fields", rule.name)
+			}
+			rule.bound = reflect.New(elem).Elem()
+			if err := scalar.ParseValue(rule.bound, rule.arg); err != nil {
+				return nil, fmt.Errorf("error processing %s: %v", part, err)
+			}
+		case "len", "minlen", "maxlen":
+			if !isCollection && t.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s can only be used with string, slice, array, or map fields", rule.name)
+			}
+			n, err := strconv.Atoi(rule.arg)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("error processing %s: expected a non-negative integer", part)
+			}
+			rule.length = n
+		case "nonempty":
+			if !isCollection && t.Kind() != reflect.String {
+				return nil, fmt.Errorf("nonempty can only be used with string, slice, array, or map fields")
+			}
+		case "regex":
+			if elem == nil || elem.Kind() != reflect.String {
+				return nil, fmt.Errorf("regex can only be used with string fields")
+			}
+			re, err := regexp.Compile(rule.arg)
+			if err != nil {
+				return nil, fmt.Errorf("error processing %s: %v", part, err)
+			}
+			rule.re = re
+		case "oneof":
+			if elem == nil {
+				return nil, fmt.Errorf("oneof cannot be used with map fields")
+			}
+			if rule.arg == "" {
+				return nil, fmt.Errorf("oneof must list at least one value")
+			}
+			rule.options = strings.Split(rule.arg, "|")
+		case "exists", "file", "dir":
+			if elem == nil || elem.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s can only be used with string fields", rule.name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown validation rule %s", rule.name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// isNumeric returns true if the type is an integer or floating point number,
+// including types such as time.Duration
+func isNumeric(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// compareNumbers returns -1, 0, or 1 depending on whether a is less than, equal
+// to, or greater than b, which must have the same numeric type
+func compareNumbers(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compare(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compare(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	default:
+		return compare(a.Float() < b.Float(), a.Float() > b.Float())
+	}
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// check applies a rule to a value, which is the whole field for the rules about
+// length and each element of a slice or array for the other rules. It returns a
+// description of the problem, such as "must be at least 1", or the empty string
+// if the value is valid.
+func (r *validationRule) check(v reflect.Value) string {
+	switch r.name {
+	case "min":
+		if compareNumbers(v, r.bound) < 0 {
+			return "must be at least " + r.arg
+		}
+	case "max":
+		if compareNumbers(v, r.bound) > 0 {
+			return "must be at most " + r.arg
+		}
+	case "len":
+		if v.Len() != r.length {
+			return fmt.Sprintf("must have length %d", r.length)
+		}
+	case "minlen":
+		if v.Len() < r.length {
+			return fmt.Sprintf("must have length at least %d", r.length)
+		}
+	case "maxlen":
+		if v.Len() > r.length {
+			return fmt.Sprintf("must have length at most %d", r.length)
+		}
+	case "nonempty":
+		if v.Len() == 0 {
+			return "must not be empty"
+		}
+	case "regex":
+		if !r.re.MatchString(v.String()) {
+			return "must match " + r.re.String()
+		}
+	case "oneof":
+		if !contains(r.options, fmt.Sprint(v.Interface())) {
+			return "must be one of " + strings.Join(r.options, ", ")
+		}
+	case "exists":
+		if _, err := os.Stat(v.String()); err != nil {
+			return "must be an existing path"
+		}
+	case "file":
+		if info, err := os.Stat(v.String()); err != nil || !info.Mode().IsRegular() {
+			return "must be an existing file"
+		}
+	case "dir":
+		if info, err := os.Stat(v.String()); err != nil || !info.IsDir() {
+			return "must be an existing directory"
+		}
+	}
+	return ""
+}
+
+// isElementRule returns true for the rules that apply to each element of a slice
+// or array rather than to the slice or array as a whole
+func (r *validationRule) isElementRule() bool {
+	switch r.name {
+	case "len", "minlen", "maxlen", "nonempty":
+		return false
+	default:
+		return true
+	}
+}
+
+// checkValidation applies the rules from the validate tag of each active spec
+// that has a value, whether from the command line, a source, or a default
+func (p *Parser) checkValidation(active []*spec) error {
+	var errs Errors
+	for _, spec := range active {
+		if len(spec.validation) == 0 || p.origins[spec].Kind == OriginUnset {
+			continue
+		}
+
+		v := p.val(spec.dest)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				continue
+			}
+			v = v.Elem()
+		}
+
+		for _, rule := range spec.validation {
+			values := []reflect.Value{v}
+			if rule.isElementRule() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+				values = values[:0]
+				for i := 0; i < v.Len(); i++ {
+					values = append(values, reflect.Indirect(v.Index(i)))
+				}
+			}
+
+			for _, value := range values {
+				problem := rule.check(value)
+				if problem == "" {
+					continue
+				}
+				err := &ValidationError{
+					Option:     optionOf(spec),
+					Arg:        p.describeProvided(spec),
+					Rule:       rule.name,
+					Value:      fmt.Sprint(value.Interface()),
+					Problem:    problem,
+					Subcommand: p.subcommandPath(),
+				}
+				if !p.config.CollectErrors {
+					return err
+				}
+				errs = append(errs, err)
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// callValidators calls Validate on each struct that implements Validator,
+// starting with the innermost selected subcommand and ending with the top-level
+// destination structs. Within each struct, Validate is called on its embedded
+// structs first, innermost first, and then on the struct itself.
+func (p *Parser) callValidators(cmds []*command) error {
+	for i := len(cmds) - 1; i >= 0; i-- {
+		subcommand := p.subcommand[:i]
+		if i > 0 {
+			if err := validateStruct(p.val(cmds[i].dest), subcommand); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, root := range p.roots {
+			if err := validateStruct(root, subcommand); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateStruct calls Validate on each exported struct embedded in the struct
+// that a pointer points to, innermost first, and then on the struct itself.
+// Embedded structs tagged with arg:"-" are skipped, as they are when collecting
+// options. A Validate method that the struct may have by promotion from its only
+// embedded struct with a Validate method is called once, on the struct itself.
+func validateStruct(ptr reflect.Value, subcommand []string) error {
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct || !ptr.CanInterface() {
+		return nil
+	}
+
+	s := ptr.Elem()
+	_, hasValidate := ptr.Interface().(Validator)
+	if !hasValidate || !promotesValidate(s.Type()) {
+		for i := 0; i < s.NumField(); i++ {
+			field := s.Type().Field(i)
+			if !field.Anonymous || field.Type.Kind() != reflect.Struct || !isExported(field.Name) || field.Tag.Get("arg") == "-" {
+				continue
+			}
+			if err := validateStruct(s.Field(i).Addr(), subcommand); err != nil {
+				return err
+			}
+		}
+	}
+
+	if v, ok := ptr.Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return &ValidatorError{Subcommand: append([]string{}, subcommand...), Err: err}
+		}
+	}
+	return nil
+}
+
+// promotesValidate returns true if the Validate method of a struct type may be the
+// one promoted from an embedded field. This is only possible when exactly one
+// embedded field has a Validate method and the method is in the method set of the
+// struct type exactly when it is in the method set of the type of that field. A
+// struct that declares its own Validate as well is then responsible for calling
+// the one it hides, as with any other method in Go.
+func promotesValidate(t reflect.Type) bool {
+	var from []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.Anonymous {
+			continue
+		}
+		ft := field.Type
+		if ft.Kind() != reflect.Ptr {
+			ft = reflect.PtrTo(ft)
+		}
+		if _, ok := ft.MethodByName("Validate"); ok {
+			from = append(from, field.Type)
+		}
+	}
+	if len(from) != 1 {
+		return false
+	}
+	_, inStruct := t.MethodByName("Validate")
+	_, inField := from[0].MethodByName("Validate")
+	return inStruct == inField
+}
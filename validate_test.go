@@ -0,0 +1,395 @@
+package arg
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMinMax(t *testing.T) {
+	var args struct {
+		Port int `validate:"min=1,max=65535"`
+	}
+	err := parse("--port 8080", &args)
+	require.NoError(t, err)
+
+	args.Port = 0
+	err = parse("--port 0", &args)
+	var e *ValidationError
+	require.True(t, errors.As(err, &e))
+	assert.Equal(t, "min", e.Rule)
+	assert.Equal(t, "0", e.Value)
+	assert.EqualError(t, err, "--port must be at least 1 (got 0)")
+
+	err = parse("--port=70000", &args)
+	assert.EqualError(t, err, "--port must be at most 65535 (got 70000)")
+}
+
+func TestValidateDuration(t *testing.T) {
+	var args struct {
+		Timeout time.Duration `validate:"min=1s"`
+	}
+	err := parse("--timeout 5s", &args)
+	require.NoError(t, err)
+
+	args.Timeout = 0
+	err = parse("--timeout 10ms", &args)
+	assert.EqualError(t, err, "--timeout must be at least 1s (got 10ms)")
+}
+
+func TestValidateFromEnv(t *testing.T) {
+	var args struct {
+		Workers int `arg:"env:VALIDATE_WORKERS" validate:"min=1"`
+	}
+	_, err := parseWithEnv(Config{}, "", []string{"VALIDATE_WORKERS=0"}, &args)
+	assert.EqualError(t, err, "environment variable VALIDATE_WORKERS must be at least 1 (got 0)")
+}
+
+func TestValidateDefault(t *testing.T) {
+	var args struct {
+		Workers int `default:"0" validate:"min=1"`
+	}
+	err := parse("", &args)
+	assert.EqualError(t, err, "--workers must be at least 1 (got 0)")
+}
+
+func TestValidateSkipsUnsetOptions(t *testing.T) {
+	var args struct {
+		Workers int    `validate:"min=1"`
+		Name    string `validate:"nonempty"`
+	}
+	err := parse("", &args)
+	require.NoError(t, err)
+}
+
+func TestValidateLength(t *testing.T) {
+	type lengthArgs struct {
+		Code  string   `validate:"len=3"`
+		Tags  []string `validate:"minlen=1,maxlen=2"`
+		Label string   `arg:"env:VALIDATE_LABEL" validate:"nonempty"`
+	}
+	var args lengthArgs
+	err := parse("--code abc --tags a b", &args)
+	require.NoError(t, err)
+
+	args = lengthArgs{}
+	err = parse("--code abcd", &args)
+	assert.EqualError(t, err, "--code must have length 3 (got abcd)")
+
+	args = lengthArgs{}
+	err = parse("--tags a b c", &args)
+	assert.EqualError(t, err, "--tags must have length at most 2 (got [a b c])")
+
+	args = lengthArgs{}
+	_, err = parseWithEnv(Config{}, "", []string{"VALIDATE_LABEL="}, &args)
+	assert.EqualError(t, err, "environment variable VALIDATE_LABEL must not be empty")
+}
+
+func TestValidateRegexAndOneOf(t *testing.T) {
+	type regexArgs struct {
+		Name  string `validate:"regex=^[a-z]{1,8}(,[a-z]+)?$"`
+		Sizes []int  `validate:"oneof=1|2|4"`
+		Mode  string `arg:"positional" validate:"oneof=fast|slow"`
+	}
+	var args regexArgs
+	err := parse("fast --name abc,def --sizes 1 4", &args)
+	require.NoError(t, err)
+
+	args = regexArgs{}
+	err = parse("--name ABC", &args)
+	assert.EqualError(t, err, "--name must match ^[a-z]{1,8}(,[a-z]+)?$ (got ABC)")
+
+	args = regexArgs{}
+	err = parse("--sizes 1 3", &args)
+	assert.EqualError(t, err, "--sizes must be one of 1, 2, 4 (got 3)")
+
+	args = regexArgs{}
+	err = parse("medium", &args)
+	assert.EqualError(t, err, "MODE must be one of fast, slow (got medium)")
+}
+
+func TestValidatePaths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(file, []byte("{}"), 0644))
+
+	type pathArgs struct {
+		Config string `validate:"file"`
+		Output string `validate:"dir"`
+		Input  string `validate:"exists"`
+	}
+	var args pathArgs
+	err := parse("--config "+file+" --output "+dir+" --input "+file, &args)
+	require.NoError(t, err)
+
+	args = pathArgs{}
+	err = parse("--config "+dir, &args)
+	assert.EqualError(t, err, "--config must be an existing file (got "+dir+")")
+
+	args = pathArgs{}
+	err = parse("--output "+file, &args)
+	assert.EqualError(t, err, "--output must be an existing directory (got "+file+")")
+
+	missing := filepath.Join(dir, "missing")
+	args = pathArgs{}
+	err = parse("--input "+missing, &args)
+	assert.EqualError(t, err, "--input must be an existing path (got "+missing+")")
+}
+
+func TestValidateCollectErrors(t *testing.T) {
+	var args struct {
+		A int `validate:"min=1"`
+		B int `validate:"max=1"`
+	}
+	_, err := parseWithEnv(Config{CollectErrors: true}, "--a 0 --b 2", nil, &args)
+	var errs Errors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "--a must be at least 1 (got 0)")
+	assert.EqualError(t, errs[1], "--b must be at most 1 (got 2)")
+}
+
+func TestValidateTagErrors(t *testing.T) {
+	var minOnString struct {
+		Name string `validate:"min=1"`
+	}
+	err := parse("", &minOnString)
+	assert.EqualError(t, err, ".Name: min can only be used with numeric fields")
+
+	var badBound struct {
+		Port int `validate:"max=lots"`
+	}
+	err = parse("", &badBound)
+	assert.EqualError(t, err, `.Port: error processing max=lots: strconv.ParseInt: parsing "lots": invalid syntax`)
+
+	var lenOnInt struct {
+		Port int `validate:"len=2"`
+	}
+	err = parse("", &lenOnInt)
+	assert.EqualError(t, err, ".Port: len can only be used with string, slice, array, or map fields")
+
+	var badRegex struct {
+		Name string `validate:"regex=("`
+	}
+	err = parse("", &badRegex)
+	assert.Error(t, err)
+
+	var unknown struct {
+		Name string `validate:"shiny"`
+	}
+	err = parse("", &unknown)
+	assert.EqualError(t, err, ".Name: unknown validation rule shiny")
+}
+
+type validatedArgs struct {
+	Foo string
+	Bar string
+}
+
+func (a *validatedArgs) Validate() error {
+	if a.Foo == "" && a.Bar == "" {
+		return errors.New("you must provide either --foo or --bar")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	var args validatedArgs
+	err := parse("--foo x", &args)
+	require.NoError(t, err)
+
+	args = validatedArgs{}
+	err = parse("", &args)
+	require.Error(t, err)
+	assert.EqualError(t, err, "you must provide either --foo or --bar")
+
+	var verr *ValidatorError
+	require.True(t, errors.As(err, &verr))
+	assert.Empty(t, verr.Subcommand)
+}
+
+type ValidatedEmbedded struct {
+	Name  string
+	calls *[]string
+}
+
+func (e *ValidatedEmbedded) Validate() error {
+	*e.calls = append(*e.calls, "embedded")
+	if e.Name == "bad" {
+		return errors.New("bad name")
+	}
+	return nil
+}
+
+type validatedSub struct {
+	Count int
+	calls *[]string
+}
+
+func (s *validatedSub) Validate() error {
+	*s.calls = append(*s.calls, "sub")
+	if s.Count < 0 {
+		return errors.New("count must not be negative")
+	}
+	return nil
+}
+
+type validatedRoot struct {
+	ValidatedEmbedded
+	Sub   *validatedSub `arg:"subcommand"`
+	Other *struct {
+		Flag bool
+	} `arg:"subcommand"`
+}
+
+func TestValidatorOrder(t *testing.T) {
+	var calls []string
+	args := validatedRoot{
+		ValidatedEmbedded: ValidatedEmbedded{calls: &calls},
+		Sub:               &validatedSub{calls: &calls},
+	}
+	_, err := pparse("sub --count 1", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sub", "embedded"}, calls)
+
+	calls = nil
+	_, err = pparse("other", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"embedded"}, calls)
+}
+
+type validatedOuter struct {
+	ValidatedEmbedded
+	ValidatedInner
+	Count int
+	calls *[]string
+}
+
+type ValidatedInner struct {
+	Level int
+	calls *[]string
+}
+
+func (i ValidatedInner) Validate() error {
+	*i.calls = append(*i.calls, "inner")
+	return nil
+}
+
+func (o *validatedOuter) Validate() error {
+	*o.calls = append(*o.calls, "outer")
+	if o.Count > 10 {
+		return errors.New("count is too large")
+	}
+	return nil
+}
+
+func TestValidatorOuterAndEmbedded(t *testing.T) {
+	var calls []string
+	args := validatedOuter{
+		ValidatedEmbedded: ValidatedEmbedded{calls: &calls},
+		ValidatedInner:    ValidatedInner{calls: &calls},
+		calls:             &calls,
+	}
+	_, err := pparse("--count 1", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"embedded", "inner", "outer"}, calls)
+
+	calls = nil
+	_, err = pparse("--name bad", &args)
+	assert.EqualError(t, err, "bad name")
+	assert.Equal(t, []string{"embedded"}, calls)
+
+	calls = nil
+	_, err = pparse("--name ok --count 11", &args)
+	assert.EqualError(t, err, "count is too large")
+	assert.Equal(t, []string{"embedded", "inner", "outer"}, calls)
+}
+
+type validatedHidden struct {
+	calls *[]string
+}
+
+func (h *validatedHidden) Validate() error {
+	*h.calls = append(*h.calls, "hidden")
+	return nil
+}
+
+type validatedSkipping struct {
+	validatedHidden
+	ValidatedEmbedded `arg:"-"`
+	calls             *[]string
+}
+
+func (s *validatedSkipping) Validate() error {
+	*s.calls = append(*s.calls, "outer")
+	return nil
+}
+
+func TestValidatorSkipsUnexportedAndIgnoredEmbedded(t *testing.T) {
+	var calls []string
+	args := validatedSkipping{
+		validatedHidden:   validatedHidden{calls: &calls},
+		ValidatedEmbedded: ValidatedEmbedded{calls: &calls},
+		calls:             &calls,
+	}
+	_, err := pparse("", &args)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"outer"}, calls)
+}
+
+func TestValidatorSubcommandError(t *testing.T) {
+	var calls []string
+	args := validatedRoot{
+		ValidatedEmbedded: ValidatedEmbedded{calls: &calls},
+		Sub:               &validatedSub{calls: &calls},
+	}
+	_, err := pparse("sub --count -1", &args)
+	assert.EqualError(t, err, "count must not be negative")
+	var verr *ValidatorError
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, []string{"sub"}, verr.Subcommand)
+	assert.Equal(t, []string{"sub"}, calls)
+
+	calls = nil
+	_, err = pparse("--name bad sub --count 1", &args)
+	assert.EqualError(t, err, "bad name")
+	require.True(t, errors.As(err, &verr))
+	assert.Empty(t, verr.Subcommand)
+}
+
+func TestValidatorNotCalledAfterOtherErrors(t *testing.T) {
+	var calls []string
+	args := validatedRoot{
+		ValidatedEmbedded: ValidatedEmbedded{calls: &calls},
+		Sub:               &validatedSub{calls: &calls},
+	}
+	_, err := pparse("sub --count x", &args)
+	assert.Error(t, err)
+	assert.Empty(t, calls)
+}
+
+func TestMustParseValidatorError(t *testing.T) {
+	originalArgs := os.Args
+	defer func() {
+		os.Args = originalArgs
+	}()
+	os.Args = []string{"example", "sub", "--count", "-1"}
+
+	var calls []string
+	args := validatedRoot{
+		ValidatedEmbedded: ValidatedEmbedded{calls: &calls},
+		Sub:               &validatedSub{calls: &calls},
+	}
+	var exitCode int
+	var stdout bytes.Buffer
+	exit := func(code int) { exitCode = code }
+	mustParse(Config{Out: &stdout, Exit: exit, HelpWidth: 80}, &args)
+	assert.Equal(t, 2, exitCode)
+	assert.Equal(t, "Usage: example sub [--count COUNT]\nerror: count must not be negative\n", stdout.String())
+}